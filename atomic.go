@@ -0,0 +1,64 @@
+package cfgo
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// writeFile writes the data to a temporary file in the same directory,
+// syncs it and renames it to the filename, so that a crash or a full disk
+// never leaves a truncated file. The mode of the existing file is kept,
+// and a new file is created with the mode before umask.
+func writeFile(filename string, data []byte, mode os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+	dir, base := filepath.Split(filename)
+	fi, statErr := os.Stat(filename)
+	var (
+		tmp  *os.File
+		name string
+		err  error
+	)
+	for i := 0; i < 100; i++ {
+		name = filepath.Join(dir, "."+base+"."+strconv.FormatUint(uint64(rand.Int63()), 36)+".tmp")
+		tmp, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if !os.IsExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(name)
+		}
+	}()
+	if statErr == nil {
+		if err = tmp.Chmod(fi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(name, filename); err != nil {
+		return err
+	}
+	// persist the rename
+	if d, e := os.Open(dir); e == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
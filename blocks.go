@@ -0,0 +1,332 @@
+package cfgo
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// block is a mapping entry of the original yaml document,
+// used to keep the hand-written comments and formatting when rewriting.
+type block struct {
+	key    string
+	value  interface{}
+	head   [][]byte // comment lines before the key
+	lines  [][]byte // the key line and the lines of its value
+	indent int
+}
+
+// blockKeys returns the keys of the blocks in order.
+func blockKeys(blocks []*block) []string {
+	keys := make([]string, len(blocks))
+	for i, b := range blocks {
+		keys[i] = b.key
+	}
+	return keys
+}
+
+// splitBlocks splits the yaml mapping into entries at the lines of its keys in the node tree,
+// tail is the comment lines after the last entry.
+// ok is false if the document is not a block mapping that can be split by lines.
+func splitBlocks(src []byte) (blocks []*block, tail [][]byte, ok bool) {
+	lines := splitLines(src)
+	var doc yaml3.Node
+	if yaml3.Unmarshal(src, &doc) != nil {
+		return nil, nil, false
+	}
+	if len(doc.Content) == 0 {
+		return nil, cleanHead(lines), true
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml3.MappingNode || root.Style&yaml3.FlowStyle != 0 {
+		return nil, nil, false
+	}
+	keys := make(map[int]bool, len(root.Content)/2)
+	for i := 0; i < len(root.Content); i += 2 {
+		keys[root.Content[i].Line-1] = true
+	}
+	// the lines of the block and quoted scalars are copied verbatim,
+	// even if they look like comments
+	scalars := scalarLines(root, lines)
+	var (
+		cur     *block
+		pending [][]byte
+	)
+	for i, line := range lines {
+		switch {
+		case keys[i]:
+			cur = &block{
+				head:   cleanHead(pending),
+				lines:  [][]byte{line},
+				indent: root.Column - 1,
+			}
+			blocks = append(blocks, cur)
+			pending = nil
+		case !scalars[i] && !isContent(line):
+			pending = append(pending, line)
+		case cur == nil:
+			return nil, nil, false
+		default:
+			cur.lines = append(cur.lines, pending...)
+			cur.lines = append(cur.lines, line)
+			pending = nil
+		}
+	}
+	for _, b := range blocks {
+		var m map[interface{}]interface{}
+		if yaml.Unmarshal(joinLines(b.lines, -b.indent), &m) != nil || len(m) != 1 {
+			return nil, nil, false
+		}
+		for k, v := range m {
+			b.key, b.value = fmt.Sprint(k), v
+		}
+	}
+	return blocks, cleanHead(pending), true
+}
+
+// scalarLines returns the indexes of the lines after the first line
+// of the multi-line block and quoted scalars in the node.
+func scalarLines(root *yaml3.Node, lines [][]byte) map[int]bool {
+	inside := make(map[int]bool)
+	var walk func(n *yaml3.Node, parentIndent int)
+	walk = func(n *yaml3.Node, parentIndent int) {
+		if n.Kind == yaml3.MappingNode {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				walk(n.Content[i], n.Content[i].Column-1)
+				walk(n.Content[i+1], n.Content[i].Column-1)
+			}
+			return
+		}
+		for _, c := range n.Content {
+			walk(c, c.Column-1)
+		}
+		first := n.Line - 1
+		if n.Kind != yaml3.ScalarNode || first < 0 || first >= len(lines) {
+			return
+		}
+		col := byteOffset(lines[first], n.Column-1)
+		last := first
+		switch {
+		case n.Style&(yaml3.LiteralStyle|yaml3.FoldedStyle) != 0:
+			last = blockScalarEnd(lines, first, col, parentIndent)
+		case n.Style&(yaml3.DoubleQuotedStyle|yaml3.SingleQuotedStyle) != 0:
+			last = quotedScalarEnd(lines, first, col)
+		}
+		for i := first + 1; i <= last; i++ {
+			inside[i] = true
+		}
+	}
+	walk(root, root.Column-1)
+	return inside
+}
+
+// blockScalarEnd returns the last line of the block scalar whose indicator is at lines[first][col].
+// Its content is indented more than the parent node, by the explicit indentation if any,
+// and the trailing blank lines are kept only by the '+' chomping indicator.
+func blockScalarEnd(lines [][]byte, first, col, parentIndent int) int {
+	keep, indent := false, 0
+	if col < len(lines[first]) {
+	header:
+		for _, ch := range lines[first][col+1:] {
+			switch {
+			case ch == '+':
+				keep = true
+			case ch >= '1' && ch <= '9':
+				indent = parentIndent + int(ch-'0')
+			case ch != '-':
+				break header
+			}
+		}
+	}
+	last := first
+	for i := first + 1; i < len(lines); i++ {
+		if len(bytes.TrimSpace(lines[i])) == 0 {
+			if keep {
+				last = i
+			}
+			continue
+		}
+		if indent == 0 {
+			indent = indentOf(lines[i])
+		}
+		if indent <= parentIndent || indentOf(lines[i]) < indent {
+			break
+		}
+		last = i
+	}
+	return last
+}
+
+// quotedScalarEnd returns the line of the closing quote of the scalar
+// whose opening quote is at lines[first][col].
+func quotedScalarEnd(lines [][]byte, first, col int) int {
+	if col >= len(lines[first]) {
+		return first
+	}
+	quote := lines[first][col]
+	for i, from := first, col+1; i < len(lines); i, from = i+1, 0 {
+		line := lines[i]
+		for j := from; j < len(line); j++ {
+			switch {
+			case quote == '"' && line[j] == '\\':
+				j++
+			case line[j] == quote && quote == '\'' && j+1 < len(line) && line[j+1] == '\'':
+				j++
+			case line[j] == quote:
+				return i
+			}
+		}
+	}
+	return len(lines) - 1
+}
+
+// byteOffset returns the offset of the column, which counts the characters.
+func byteOffset(line []byte, column int) int {
+	offset := 0
+	for i := 0; i < column && offset < len(line); i++ {
+		_, size := utf8.DecodeRune(line[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// children splits the value of the block, if it is a block mapping.
+func (b *block) children() ([]*block, [][]byte, bool) {
+	if _, ok := b.value.(map[interface{}]interface{}); !ok || len(b.lines) < 2 {
+		return nil, nil, false
+	}
+	// the key line must not carry a part of the value
+	var m map[interface{}]interface{}
+	if yaml.Unmarshal(joinLines(b.lines[:1], -b.indent), &m) != nil || len(m) != 1 {
+		return nil, nil, false
+	}
+	for _, v := range m {
+		if v != nil {
+			return nil, nil, false
+		}
+	}
+	return splitBlocks(joinLines(b.lines[1:], 0))
+}
+
+// mergeBlock renders the new block based on the original one.
+// The original text is kept for the values that are not changed,
+// and the entries of a changed mapping keep their order and comments.
+func mergeBlock(orig, nb *block) [][]byte {
+	if reflect.DeepEqual(orig.value, nb.value) {
+		return orig.lines
+	}
+	subs, tail, ok := orig.children()
+	if !ok || len(subs) == 0 {
+		return shiftLines(nb.lines, orig.indent-nb.indent)
+	}
+	nsubs, _, ok := nb.children()
+	if !ok {
+		return shiftLines(nb.lines, orig.indent-nb.indent)
+	}
+	lines := [][]byte{orig.lines[0]}
+	done := make(map[string]bool, len(nsubs))
+	for _, sub := range subs {
+		for _, nsub := range nsubs {
+			if sub.key == nsub.key && !done[sub.key] {
+				done[sub.key] = true
+				lines = append(lines, sub.head...)
+				lines = append(lines, mergeBlock(sub, nsub)...)
+				break
+			}
+		}
+	}
+	for _, nsub := range nsubs {
+		if !done[nsub.key] {
+			lines = append(lines, shiftLines(nsub.lines, subs[0].indent-nsub.indent)...)
+		}
+	}
+	return append(lines, tail...)
+}
+
+func splitLines(b []byte) [][]byte {
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	if len(b) == 0 {
+		return nil
+	}
+	return bytes.Split(b, []byte("\n"))
+}
+
+// joinLines joins the lines with line end, shifting their indentation.
+func joinLines(lines [][]byte, shift int) []byte {
+	var buf bytes.Buffer
+	for _, line := range shiftLines(lines, shift) {
+		buf.Write(line)
+		buf.Write(lineend)
+	}
+	return buf.Bytes()
+}
+
+// shiftLines adds (shift > 0) or removes (shift < 0) the leading spaces of the lines.
+func shiftLines(lines [][]byte, shift int) [][]byte {
+	if shift == 0 {
+		return lines
+	}
+	shifted := make([][]byte, len(lines))
+	for i, line := range lines {
+		if shift > 0 {
+			shifted[i] = append(bytes.Repeat([]byte(" "), shift), line...)
+			continue
+		}
+		n := indentOf(line)
+		if n > -shift {
+			n = -shift
+		}
+		shifted[i] = line[n:]
+	}
+	return shifted
+}
+
+// dropLine removes the lines that are equal to line, ignoring the spaces around.
+func dropLine(lines [][]byte, line []byte) [][]byte {
+	line = bytes.TrimSpace(line)
+	var kept [][]byte
+	for _, l := range lines {
+		if !bytes.Equal(bytes.TrimSpace(l), line) {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+// cleanHead drops the leading and trailing blank lines, the document markers
+// and the dividing line, which are generated by cfgo.
+func cleanHead(lines [][]byte) [][]byte {
+	var head [][]byte
+	divider := bytes.TrimSpace(dividingLine)
+	for _, line := range lines {
+		trimmed := bytes.TrimSpace(line)
+		if bytes.Equal(trimmed, divider) || bytes.HasPrefix(line, []byte("---")) ||
+			bytes.HasPrefix(line, []byte("...")) || bytes.HasPrefix(line, []byte("%")) {
+			continue
+		}
+		if len(trimmed) == 0 && len(head) == 0 {
+			continue
+		}
+		head = append(head, line)
+	}
+	for len(head) > 0 && len(bytes.TrimSpace(head[len(head)-1])) == 0 {
+		head = head[:len(head)-1]
+	}
+	return head
+}
+
+func indentOf(line []byte) int {
+	return len(line) - len(bytes.TrimLeft(line, " "))
+}
+
+// isContent reports whether the line is neither blank, nor a comment, nor a document marker.
+func isContent(line []byte) bool {
+	trimmed := bytes.TrimSpace(line)
+	return len(trimmed) > 0 && trimmed[0] != '#' &&
+		!bytes.HasPrefix(line, []byte("---")) && !bytes.HasPrefix(line, []byte("...")) && line[0] != '%'
+}
@@ -35,30 +35,21 @@ package cfgo
 import (
 	"bytes"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"sort"
-	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
-var (
-	defaultCfgo *Cfgo
-	defaultInit sync.Once
-)
-
 // Default returns default config
 func Default() *Cfgo {
-	defaultInit.Do(func() {
-		defaultCfgo = MustGet("config/config.yaml")
-	})
-	return defaultCfgo
+	return defaultRegistry.Default()
 }
 
 // Filename returns default config file name.
@@ -71,6 +62,16 @@ func AllowAppsShare(allow bool) {
 	Default().AllowAppsShare(allow)
 }
 
+// SetLockTimeout sets the timeout of locking the default config file.
+func SetLockTimeout(timeout time.Duration) {
+	Default().SetLockTimeout(timeout)
+}
+
+// KeepBackup keeps the previous version of the default config file.
+func KeepBackup(keep bool) {
+	Default().KeepBackup(keep)
+}
+
 // MustReg is similar to Reg(), but panic if having error.
 func MustReg(section string, structPtr Config) {
 	Default().MustReg(section, structPtr)
@@ -82,6 +83,11 @@ func Reg(section string, structPtr Config) error {
 	return Default().Reg(section, structPtr)
 }
 
+// Unreg unregisters the section of the default config.
+func Unreg(section string, drop ...bool) error {
+	return Default().Unreg(section, drop...)
+}
+
 // IsReg to determine whether the section is registered.
 func IsReg(section string) bool {
 	return Default().IsReg(section)
@@ -103,40 +109,71 @@ func Content() []byte {
 }
 
 // ReloadAll reloads all configs.
-func ReloadAll() error {
-	lock.Lock()
-	defer lock.Unlock()
-	var errs []string
-	for _, c := range cfgos {
-		if err := c.Reload(); err != nil {
-			errs = append(errs, err.Error())
-		}
-	}
-	if len(errs) > 0 {
-		return fmt.Errorf("%s", strings.Join(errs, string(lineend)))
-	}
-	return nil
+// The sections are reloaded only if they are changed, unless force is true.
+func ReloadAll(force ...bool) error {
+	return defaultRegistry.ReloadAll(force...)
 }
 
 // Reload reloads default config.
-func Reload() error {
-	return Default().Reload()
+// The sections are reloaded only if they are changed, unless force is true.
+func Reload(force ...bool) error {
+	return Default().Reload(force...)
 }
 
 type (
 	// Cfgo a whole config
 	Cfgo struct {
 		filename        string
+		codec           Codec
 		originalContent []byte
 		content         []byte
 		regConfigs      map[string]Config
+		baselines       map[string]reflect.Value
 		extraConfigs    map[string]interface{}
 		regSections     Sections
 		extraSections   Sections
+		blocks          []*block
+		tailComments    [][]byte
+		overrides       map[string]overrides
+		bound           map[string][]byte // the yaml of the values last bound per section
+		envPrefix       string
+		envKeyFunc      EnvKeyFunc
+		flagValues      map[string]map[string]string
 		allowAppsShare  bool
+		fileMode        os.FileMode
+		writeBack       bool
+		readOnly        bool
+		writeBackFile   string
+		profile         string
+		profileSet      bool
+		mergeStrategy   MergeStrategy
+		overlays        map[string][]byte      // the sections of the profile overlay
+		overlaid        map[string]interface{} // the non-automated sections merged with the overlay
+		pattern         string                 // the directory or glob of the config files
+		defaultFile     string
+		dropIns         []*dropIn
+		origins         map[string]*dropIn     // the drop-in files of the sections
+		fileSections    map[string]interface{} // the sections of the default file
+		shadowed        Sections               // the sections overridden by the later files
+		dividingLine    []byte
+		watch           bool
+		watchErrFunc    func(error)
+		lockTimeout     time.Duration
+		unknownKeyMode  UnknownKeyMode
+		backup          bool
+		watcher         *watcher
+		published       map[string]published   // the sections that the last events are sent for
+		detached        map[string]interface{} // the sections being unregistered, nil if dropped
+		updating        string                 // the section being updated, the others are kept as in the file
+		orders          map[string][]string    // the hand-written order of the sections per file
+		written         map[string][]string    // the order of the sections last written per file
+		registry        *Registry
+		key             string // the absolute path given to Get, the key in the registry
+		subscribers     []*subscriber
+		subLock         sync.Mutex
 		lc              sync.RWMutex
 	}
-	// Config must be struct pointer
+	// Config must be struct pointer, it may implement DiffConfig
 	Config interface {
 		// load or reload config to app
 		Reload(bind BindFunc) error
@@ -146,8 +183,6 @@ type (
 )
 
 var (
-	cfgos   = make(map[string]*Cfgo, 1)
-	lock    sync.Mutex
 	lineend = func() []byte {
 		if runtime.GOOS == "windows" {
 			return []byte("\r\n")
@@ -159,44 +194,15 @@ var (
 )
 
 // MustGet creates a new Cfgo
-func MustGet(filename string, allowAppsShare ...bool) *Cfgo {
-	c, err := Get(filename, allowAppsShare...)
-	if err != nil {
-		panic(err)
-	}
-	return c
+func MustGet(filename string, opts ...Option) *Cfgo {
+	return defaultRegistry.MustGet(filename, opts...)
 }
 
-// Get creates or gets a Cfgo.
-func Get(filename string, allowAppsShare ...bool) (*Cfgo, error) {
-	abs, err := filepath.Abs(filename)
-	if err != nil {
-		return nil, fmt.Errorf("[cfgo] %s", err.Error())
-	}
-	lock.Lock()
-	defer lock.Unlock()
-	c := cfgos[abs]
-	if c != nil {
-		return c, nil
-	}
-	c = &Cfgo{
-		filename:        abs,
-		originalContent: []byte{},
-		content:         []byte{},
-		regConfigs:      make(map[string]Config),
-		extraConfigs:    make(map[string]interface{}),
-		regSections:     make([]*Section, 0, 1),
-		extraSections:   make([]*Section, 0),
-	}
-	if len(allowAppsShare) > 0 && allowAppsShare[0] {
-		c.allowAppsShare = true
-	}
-	err = c.reload()
-	if err != nil {
-		return nil, fmt.Errorf("[cfgo] %s", err.Error())
-	}
-	cfgos[abs] = c
-	return c, nil
+// Get creates or gets a Cfgo of the default registry.
+// The codec of the file is selected by its extension, see RegCodec.
+// The options are applied when the Cfgo is created, they are ignored if it exists.
+func Get(filename string, opts ...Option) (*Cfgo, error) {
+	return defaultRegistry.Get(filename, opts...)
 }
 
 // Filename returns the config file name.
@@ -205,10 +211,27 @@ func (c *Cfgo) Filename() string {
 }
 
 // AllowAppsShare allows other applications to share the configuration file.
+// The processes lock the file '<filename>.lock' while syncing it.
 func (c *Cfgo) AllowAppsShare(allow bool) {
 	c.allowAppsShare = allow
 }
 
+// SetLockTimeout sets the timeout of locking the config file,
+// which is shared by the applications, DefaultLockTimeout by default.
+func (c *Cfgo) SetLockTimeout(timeout time.Duration) {
+	c.lc.Lock()
+	c.lockTimeout = timeout
+	c.lc.Unlock()
+}
+
+// KeepBackup keeps a copy of the previous version of the config file,
+// with the suffix '.bak', when the file is rewritten.
+func (c *Cfgo) KeepBackup(keep bool) {
+	c.lc.Lock()
+	c.backup = keep
+	c.lc.Unlock()
+}
+
 // MustReg is similar to Reg(), but panic if having error.
 func (c *Cfgo) MustReg(section string, structPtr Config) {
 	err := c.Reg(section, structPtr)
@@ -224,38 +247,81 @@ func (c *Cfgo) Reg(section string, structPtr Config) error {
 	defer c.lc.Unlock()
 
 	t := reflect.TypeOf(structPtr)
-	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
-		return fmt.Errorf("[cfgo] not a struct pointer:\nsection: %s\nstructPtr: %s", section, t.String())
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("[cfgo] %w:\nsection: %s\nstructPtr: %T", ErrNotStructPtr, section, structPtr)
 	}
 	if s, ok := c.regConfigs[section]; ok {
-		return fmt.Errorf("[cfgo] multiple section: %s\nexisted: %s | adding: %s", section, reflect.TypeOf(s).String(), t.String())
+		return fmt.Errorf("[cfgo] %w: %s\nexisted: %s | adding: %s", ErrDuplicateSection, section, reflect.TypeOf(s).String(), t.String())
 	}
 
 	c.regConfigs[section] = structPtr
+	// the initial values are used as the defaults on every reload
+	c.baselines[section] = deepCopy(reflect.ValueOf(structPtr))
 
 	// sync config
-	var init bool
-	var load = func(s string, _ Config, b []byte) error {
-		if s == section {
-			init = true
-			return structPtr.Reload(func() error {
-				return yaml.Unmarshal(b, structPtr)
-			})
+	var load = func(s string, _ Config, b []byte) (func() error, error) {
+		if s != section {
+			return nil, nil
 		}
-		return nil
+		reload, _, err := c.bindFunc(section, structPtr, b)
+		return reload, err
 	}
-	err := c.sync(load)
-	if err != nil {
-		return err
+	return c.sync(load, c.writeBack)
+}
+
+// Unreg unregisters the section, its current values are kept as a non-automated section,
+// or removed from the file if drop is true. The file is written as Reg does.
+func (c *Cfgo) Unreg(section string, drop ...bool) error {
+	c.lc.Lock()
+	defer c.lc.Unlock()
+	structPtr, ok := c.regConfigs[section]
+	if !ok {
+		return fmt.Errorf("[cfgo] %w: %s", ErrSectionNotFound, section)
+	}
+	var value interface{}
+	if len(drop) == 0 || !drop[0] {
+		for _, s := range c.regSections {
+			if s.title == section {
+				if err := yaml.Unmarshal(s.single, &value); err != nil {
+					return fmt.Errorf("[cfgo] %w", c.sectionError(section, err))
+				}
+			}
+		}
 	}
-	if !init {
-		err = structPtr.Reload(func() error {
-			return nil
-		})
+	baseline, o, bound := c.baselines[section], c.overrides[section], c.bound[section]
+	delete(c.regConfigs, section)
+	delete(c.baselines, section)
+	delete(c.overrides, section)
+	delete(c.bound, section)
+
+	c.detached = map[string]interface{}{section: value}
+	defer func() { c.detached = nil }()
+	err := c.sync(func(string, Config, []byte) (func() error, error) {
+		return nil, nil
+	}, c.writeBack)
+	if err != nil {
+		c.regConfigs[section], c.baselines[section], c.overrides[section] = structPtr, baseline, o
+		if bound != nil {
+			c.bound[section] = bound
+		}
 	}
 	return err
 }
 
+// Close stops watching the file and the subscriptions, and removes the Cfgo from its registry,
+// so that Get creates a new one for the file.
+func (c *Cfgo) Close() error {
+	c.Unwatch()
+	c.subLock.Lock()
+	subscribers := c.subscribers
+	c.subLock.Unlock()
+	for _, s := range subscribers {
+		c.unsubscribe(s)
+	}
+	c.registry.remove(c)
+	return nil
+}
+
 // IsReg to determine whether the section is registered.
 func (c *Cfgo) IsReg(section string) bool {
 	c.lc.RLock()
@@ -271,6 +337,9 @@ func (c *Cfgo) GetSection(section string) (interface{}, bool) {
 	if v, ok := c.regConfigs[section]; ok {
 		return v, ok
 	}
+	if v, ok := c.overlaid[section]; ok {
+		return v, ok
+	}
 	v, ok := c.extraConfigs[section]
 	return v, ok
 }
@@ -284,12 +353,19 @@ func (c *Cfgo) BindSection(section string, v interface{}) error {
 			return yaml.Unmarshal(s.single, v)
 		}
 	}
+	if o, ok := c.overlaid[section]; ok {
+		b, err := yaml.Marshal(o)
+		if err != nil {
+			return err
+		}
+		return yaml.Unmarshal(b, v)
+	}
 	for _, s := range c.extraSections {
 		if section == s.title {
 			return yaml.Unmarshal(s.single, v)
 		}
 	}
-	return fmt.Errorf("not exist config section: %s", section)
+	return fmt.Errorf("[cfgo] %w: %s", ErrSectionNotFound, section)
 }
 
 // Content returns yaml config bytes.
@@ -300,111 +376,332 @@ func (c *Cfgo) Content() []byte {
 }
 
 // Reload reloads config.
-func (c *Cfgo) Reload() error {
+// The Reload method of a registered struct is called only if the values of
+// the section are changed since it was last bound, unless force is true.
+func (c *Cfgo) Reload(force ...bool) error {
 	c.lc.Lock()
 	defer c.lc.Unlock()
-	return c.reload()
+	return c.reload(len(force) > 0 && force[0])
 }
 
-func (c *Cfgo) reload() error {
-	return c.sync(func(_ string, setting Config, b []byte) error {
-		return setting.Reload(func() error {
-			return yaml.Unmarshal(b, setting)
-		})
-	})
+func (c *Cfgo) reload(force bool) error {
+	return c.sync(func(section string, setting Config, b []byte) (func() error, error) {
+		reload, changed, err := c.bindFunc(section, setting, b)
+		if err != nil || (!changed && !force) {
+			return nil, err
+		}
+		return reload, nil
+	}, c.writeBack)
 }
 
+// bindFunc prepares the section on a copy of the registered struct,
+// and returns the function that reloads the struct with the prepared values,
+// and whether the values are different from the last bound ones.
+func (c *Cfgo) bindFunc(section string, structPtr Config, b []byte) (func() error, bool, error) {
+	v, o, err := c.prepare(section, structPtr, b)
+	if err != nil {
+		return nil, false, err
+	}
+	single, err := yaml.Marshal(v.Interface())
+	if err != nil {
+		return nil, false, err
+	}
+	last, ok := c.bound[section]
+	bind := c.commitFunc(section, structPtr, v, o, single)
+	return func() error { return c.callReload(section, structPtr, bind, single) }, !ok || !bytes.Equal(last, single), nil
+}
+
+// commitFunc returns the function that binds the prepared values to the struct.
+func (c *Cfgo) commitFunc(section string, structPtr Config, v reflect.Value, o overrides, single []byte) BindFunc {
+	return func() error {
+		assign(reflect.ValueOf(structPtr).Elem(), v.Elem())
+		c.overrides[section] = o
+		c.bound[section] = single
+		return nil
+	}
+}
+
+// prepare unmarshals the section bytes from the file to a copy of the struct
+// with the default values, applies the runtime overrides and validates the result.
+func (c *Cfgo) prepare(section string, structPtr Config, b []byte) (reflect.Value, overrides, error) {
+	v := deepCopy(c.baselines[section])
+	root := v.Elem()
+	if err := setDefaults(v.Interface()); err != nil {
+		return v, nil, err
+	}
+	if b != nil {
+		if err := yaml.Unmarshal(b, v.Interface()); err != nil {
+			return v, nil, err
+		}
+		if err := c.checkUnknownKeys(root.Type(), b); err != nil {
+			return v, nil, err
+		}
+	}
+	keepIgnored(root, reflect.ValueOf(structPtr).Elem())
+	var o overrides
+	if err := c.applyOverlay(section, root, b, &o); err != nil {
+		return v, nil, err
+	}
+	if err := c.applyEnv(section, root, &o); err != nil {
+		return v, nil, err
+	}
+	if err := c.applyFlags(section, root, &o); err != nil {
+		return v, nil, err
+	}
+	return v, o, validate(v.Interface())
+}
+
+// clean resets the state read from the files, the old state may be kept by fileState,
+// so the slices are not reused.
 func (c *Cfgo) clean() {
-	c.originalContent = c.originalContent[:0]
-	c.content = c.content[:0]
+	c.originalContent = []byte{}
+	c.content = []byte{}
 	c.extraConfigs = make(map[string]interface{})
-	c.regSections = c.regSections[:0]
-	c.extraSections = c.extraSections[:0]
+	c.regSections = make([]*Section, 0, len(c.regConfigs))
+	c.extraSections = make([]*Section, 0)
+	c.blocks = nil
+	c.tailComments = nil
+	c.dropIns = nil
+	c.origins = nil
+	c.fileSections = nil
+	c.shadowed = nil
+	c.overlaid = nil
 }
 
-func (c *Cfgo) sync(load func(section string, setting Config, b []byte) error) (err error) {
+// loadFunc prepares the registered section from its file bytes without
+// touching the struct, and returns the function that commits it,
+// or nil if there is nothing to commit.
+type loadFunc func(section string, setting Config, b []byte) (commit func() error, err error)
+
+// sync reads the file and loads the registered sections,
+// and then renders the content and writes it to the file if persist is true.
+func (c *Cfgo) sync(load loadFunc, persist bool) (err error) {
+	// Nothing is changed if it fails
+	saved := c.fileState()
 	c.clean()
 	defer func() {
 		if err != nil {
-			err = fmt.Errorf("[cfgo] %s", err.Error())
+			saved.restore(c)
+			err = fmt.Errorf("[cfgo] %w", err)
 		}
 	}()
-	d, _ := filepath.Split(c.filename)
-	err = os.MkdirAll(d, 0777)
-	if err != nil {
-		return
+	if c.readOnly {
+		persist = false
+	} else {
+		for _, filename := range []string{c.filename, c.writeBackTarget()} {
+			d, _ := filepath.Split(filename)
+			err = os.MkdirAll(d, 0777)
+			if err != nil {
+				return
+			}
+		}
 	}
 
+	if c.allowAppsShare && !c.readOnly {
+		// Lock the read-merge-write cycle among the processes
+		var unlock func()
+		unlock, err = lockFile(c.filename, c.lockTimeout)
+		if err != nil {
+			return
+		}
+		defer unlock()
+	}
+
+	// Restore the structs
+	var committed []*snapshot
+	defer func() {
+		if err != nil {
+			for i := len(committed) - 1; i >= 0; i-- {
+				committed[i].rollback()
+			}
+		}
+	}()
+
 	// unmarshal
-	err = c.read(load)
+	var l *loaded
+	for i := 0; ; i++ {
+		l, err = c.read(load)
+		if err != nil {
+			return
+		}
+		// Merge again if the file is changed by someone not locking it,
+		// nothing is committed until the file is read without changes
+		if !c.allowAppsShare || i >= maxMerges {
+			break
+		}
+		if b, e := ioutil.ReadFile(c.filename); e != nil || bytes.Equal(b, c.originalContent) {
+			break
+		}
+		c.clean()
+	}
+	committed, err = c.commit(l)
 	if err != nil {
 		return
 	}
+	if err = c.createSections(l); err != nil {
+		return
+	}
 
 	// Restore the original configuration
 	defer func() {
-		if err != nil {
-			file, err := os.OpenFile(c.filename, os.O_WRONLY|os.O_SYNC|os.O_TRUNC|os.O_CREATE, 0666)
-			if err != nil {
-				return
-			}
-			defer file.Close()
-			file.Write(c.originalContent)
+		if err != nil && persist && c.writeBackTarget() == c.filename {
+			writeFile(c.filename, c.originalContent, c.fileMode)
 		}
 	}()
 
 	// marshal
-	err = c.write()
+	err = c.write(persist)
 	if err != nil {
 		return
 	}
 
+	c.publish()
 	return nil
 }
 
-func (c *Cfgo) read(load func(section string, setting Config, b []byte) error) (err error) {
-	file, err := os.OpenFile(c.filename, os.O_RDONLY|os.O_SYNC|os.O_CREATE, 0666)
-	if err != nil {
-		return
+// loaded is the registered sections prepared from the file, to be committed.
+type loaded struct {
+	keys     []string
+	values   map[string]interface{} // the sections decoded from the file
+	singles  map[string][]byte      // the sections in the file
+	expanded map[string][]byte      // the sections with the placeholders expanded
+	commits  map[string]func() error
+}
+
+// read reads the file and prepares the registered sections without touching the structs.
+func (c *Cfgo) read(load loadFunc) (l *loaded, err error) {
+	if c.readOnly {
+		// a missing file is taken as empty
+		c.originalContent, err = ioutil.ReadFile(c.filename)
+		if os.IsNotExist(err) {
+			c.originalContent, err = []byte{}, nil
+		}
+	} else {
+		var file *os.File
+		file, err = os.OpenFile(c.filename, os.O_RDONLY|os.O_SYNC|os.O_CREATE, c.fileMode)
+		if err != nil {
+			return
+		}
+		c.originalContent, err = ioutil.ReadAll(file)
+		file.Close()
 	}
-	c.originalContent, err = ioutil.ReadAll(file)
-	file.Close()
 	if err != nil {
 		return
 	}
 
-	err = yaml.Unmarshal(c.originalContent, &c.extraConfigs)
+	err = c.codec.Unmarshal(c.originalContent, &c.extraConfigs)
 	if err != nil {
+		return nil, parseError(c.filename, c.originalContent, err)
+	}
+	c.blocks, c.tailComments = c.splitBlocks(c.codec, c.originalContent)
+	c.readOrder(c.filename, c.blocks)
+	if err = c.readDropIns(); err != nil {
+		return
+	}
+	for k, v := range c.detached {
+		if v == nil {
+			delete(c.extraConfigs, k)
+		} else {
+			c.extraConfigs[k] = v
+		}
+	}
+	// the placeholders may reference the sections of all the files
+	in := newInterpolator(c.extraConfigs)
+	if err = c.readOverlay(in); err != nil {
 		return
 	}
 
-	// load config
-	var errs []string
-	var single []byte
-	for k, v := range c.extraConfigs {
-		for kk, vv := range c.regConfigs {
-			if k == kk {
-				delete(c.extraConfigs, k)
-				if single, err = yaml.Marshal(v); err != nil {
-					return
-				}
-				// load
-				if err = load(kk, vv, single); err != nil {
-					errs = append(errs, err.Error())
-				}
-				break
+	// load config in two phases,
+	// the structs are not changed unless all the sections are prepared.
+	// the sections missing from the file are loaded with nil bytes,
+	// so that the runtime overrides are still applied.
+	keys := make([]string, 0, len(c.regConfigs))
+	for k := range c.regConfigs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var errs MultiError
+	l = &loaded{
+		keys:     keys,
+		values:   make(map[string]interface{}, len(c.regConfigs)),
+		singles:  make(map[string][]byte, len(c.regConfigs)),
+		expanded: make(map[string][]byte, len(c.regConfigs)),
+		commits:  make(map[string]func() error, len(c.regConfigs)),
+	}
+	for _, k := range keys {
+		var single []byte
+		if v, ok := c.extraConfigs[k]; ok {
+			delete(c.extraConfigs, k)
+			l.values[k] = v
+			if single, err = yaml.Marshal(v); err != nil {
+				return
+			}
+			l.singles[k] = single
+			// the placeholders are expanded before unmarshalling
+			if v, err = in.expand(v, 0); err != nil {
+				errs = append(errs, c.sectionError(k, err))
+				continue
+			}
+			if single, err = yaml.Marshal(v); err != nil {
+				return
 			}
+			l.expanded[k] = single
+		}
+		// prepare
+		commit, e := load(k, c.regConfigs[k], single)
+		if e != nil {
+			errs = append(errs, c.sectionError(k, e))
+		} else if commit != nil {
+			l.commits[k] = commit
 		}
 	}
-
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
 	if len(errs) > 0 {
-		return fmt.Errorf("%s", strings.Join(errs, string(lineend)))
+		return nil, errs
+	}
+	if err = c.overlayExtras(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// commit binds the prepared sections to the structs,
+// the structs bound are rolled back by sync if anything fails later.
+func (c *Cfgo) commit(l *loaded) (committed []*snapshot, err error) {
+	for _, k := range l.keys {
+		commit, ok := l.commits[k]
+		if !ok {
+			continue
+		}
+		s := c.snapshot(k)
+		if err = commit(); err != nil {
+			s.restore()
+			return committed, c.sectionError(k, err)
+		}
+		committed = append(committed, s)
 	}
+	return committed, nil
+}
 
+// createSections creates the sections to be written from the structs and the file.
+func (c *Cfgo) createSections(l *loaded) (err error) {
 	var section *Section
+	var value interface{}
 	c.regSections = make([]*Section, 0, len(c.regConfigs))
 	for k, v := range c.regConfigs {
-		if section, err = createSection(k, v); err != nil {
+		if fileValue, ok := l.values[k]; ok && c.updating != "" && k != c.updating {
+			value = fileValue
+		} else {
+			if value, err = c.withUnknownKeys(c.persisted(k, v), l.singles[k]); err != nil {
+				return
+			}
+			if value, err = withPlaceholders(value, l.singles[k], l.expanded[k]); err != nil {
+				return
+			}
+		}
+		if section, err = c.createSection(k, value, c.origins[k]); err != nil {
 			return
 		}
 		c.regSections = append(c.regSections, section)
@@ -413,97 +710,188 @@ func (c *Cfgo) read(load func(section string, setting Config, b []byte) error) (
 
 	c.extraSections = make([]*Section, 0, len(c.extraConfigs))
 	for k, v := range c.extraConfigs {
-		if section, err = createSection(k, v); err != nil {
+		if section, err = c.createSection(k, v, c.origins[k]); err != nil {
 			return
 		}
 		c.extraSections = append(c.extraSections, section)
 	}
 	sort.Sort(c.extraSections)
-	return nil
+	return c.keepShadowed()
 }
 
-func createSection(k string, v interface{}) (section *Section, err error) {
+// splitBlocks splits the yaml document to keep its comments and formatting.
+func (c *Cfgo) splitBlocks(codec Codec, content []byte) ([]*block, [][]byte) {
+	if _, ok := codec.(yamlCodec); !ok {
+		return nil, nil
+	}
+	blocks, tail, ok := splitBlocks(content)
+	if !ok {
+		return nil, nil
+	}
+	if c.dividingLine != nil {
+		for _, b := range blocks {
+			b.head = cleanHead(dropLine(b.head, c.dividingLine))
+		}
+		tail = cleanHead(dropLine(tail, c.dividingLine))
+	}
+	return blocks, tail
+}
+
+// createSection creates the section of the file it comes from, the default file if d is nil.
+func (c *Cfgo) createSection(k string, v interface{}, d *dropIn) (section *Section, err error) {
+	origBlocks, filename := c.blocks, c.filename
+	if d != nil {
+		origBlocks, filename = d.blocks, d.filename
+	}
 	section = &Section{
 		title: k,
+		index: c.sectionIndex(filename, k),
+		file:  d,
 	}
 	var single []byte
 	if single, err = yaml.Marshal(v); err != nil {
 		return
 	}
 	section.single = single
-	var united = bytes.Replace(single, []byte("\r\n"), []byte("\n"), -1)
-	united = bytes.Replace(united, []byte("\n"), indent, -1)
-	united = append([]byte(k+":"+string(lineend)+"  "), united[:len(united)-2]...)
+	var united []byte
+	if united, err = c.fileCodec(d).Marshal(k, v); err != nil {
+		return
+	}
 	section.united = united
+
+	// keep the original comments and formatting
+	for _, orig := range origBlocks {
+		if orig.key != k {
+			continue
+		}
+		if blocks, _, ok := splitBlocks(united); ok && len(blocks) == 1 {
+			section.united = joinLines(append(orig.head, mergeBlock(orig, blocks[0])...), 0)
+		}
+		break
+	}
 	return
 }
 
-func (c *Cfgo) write() error {
-	file, err := os.OpenFile(c.filename, os.O_WRONLY|os.O_SYNC|os.O_TRUNC|os.O_CREATE, 0666)
+func (c *Cfgo) write(persist bool) error {
+	content, err := c.render(nil)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	if !persist {
+		c.content = content
+		return nil
+	}
+	target, original := c.writeBackTarget(), c.originalContent
+	if target != c.filename {
+		original, _ = ioutil.ReadFile(target)
+	}
+	if err = c.writeBackup(target, original, content); err != nil {
+		return err
+	}
+	if target == c.filename {
+		c.writtenOrder(c.filename, content)
+	}
+	if err = c.writeDropIns(); err != nil {
+		return err
+	}
+	c.content = content
+	return nil
+}
 
+// readOrder keeps the order of the sections of the file as it is hand-written,
+// while the file is only rewritten by cfgo, so that a section keeps its place
+// when it moves between the registered and the non-automated sections.
+func (c *Cfgo) readOrder(filename string, blocks []*block) {
+	keys := blockKeys(blocks)
+	if written, ok := c.written[filename]; ok && reflect.DeepEqual(written, keys) {
+		return
+	}
+	if c.orders == nil {
+		c.orders = make(map[string][]string)
+	}
+	c.orders[filename] = keys
+}
+
+// writtenOrder records the order of the sections written to the file.
+func (c *Cfgo) writtenOrder(filename string, content []byte) {
+	if c.written == nil {
+		c.written = make(map[string][]string)
+	}
+	blocks, _, _ := splitBlocks(content)
+	c.written[filename] = blockKeys(blocks)
+}
+
+// sectionIndex returns the place of the section in the hand-written order of the file,
+// the new sections follow the others.
+func (c *Cfgo) sectionIndex(filename, title string) int {
+	order := c.orders[filename]
+	for i, k := range order {
+		if k == title {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// render renders the sections of the file, the default file if d is nil.
+func (c *Cfgo) render(d *dropIn) ([]byte, error) {
+	var sections, extraSections Sections
+	for _, section := range c.regSections {
+		if section.file == d {
+			sections = append(sections, section)
+		}
+	}
+	for _, section := range append(c.extraSections, c.shadowed...) {
+		if section.file == d {
+			extraSections = append(extraSections, section)
+		}
+	}
+	sort.Sort(extraSections)
 	content := bytes.NewBuffer(c.content)
-	w := io.MultiWriter(file, content)
+	tailComments := c.tailComments
+	if d != nil {
+		content, tailComments = new(bytes.Buffer), d.tail
+	}
 
+	var blocks, extras [][]byte
 	if c.allowAppsShare {
 		// Allow multiple processes share
 
-		allSections := append(c.regSections, c.extraSections...)
+		allSections := append(sections, extraSections...)
 		sort.Sort(allSections)
-		for i, section := range allSections {
-			if i != 0 {
-				_, err = w.Write(lineend)
-				if err != nil {
-					return err
-				}
-			}
-			_, err = w.Write(section.united)
-			if err != nil {
-				return err
-			}
+		for _, section := range allSections {
+			blocks = append(blocks, section.united)
 		}
 	} else {
 		// Only single process
 
-		for i, section := range c.regSections {
-			if i != 0 {
-				_, err = w.Write(lineend)
-				if err != nil {
-					return err
-				}
-			}
-			_, err = w.Write(section.united)
-			if err != nil {
-				return err
-			}
+		for _, section := range sections {
+			blocks = append(blocks, section.united)
 		}
-		for i, section := range c.extraSections {
-			if i == 0 {
-				_, err = w.Write(lineend)
-				if err != nil {
-					return err
-				}
-				_, err = w.Write(dividingLine)
-				if err != nil {
-					return err
-				}
-			}
-			_, err = w.Write(lineend)
-			if err != nil {
-				return err
-			}
-			_, err = w.Write(section.united)
-			if err != nil {
-				return err
-			}
+		for _, section := range extraSections {
+			extras = append(extras, section.united)
 		}
 	}
+	err := c.fileCodec(d).Render(content, blocks, extras)
+	if err != nil {
+		return nil, err
+	}
+	if len(tailComments) > 0 {
+		content.Write(lineend)
+		content.Write(joinLines(tailComments, 0))
+	}
+	return content.Bytes(), nil
+}
 
-	c.content = content.Bytes()
-	return nil
+// writeBackup writes the content to the file, and keeps the original content
+// as the backup if it is enabled.
+func (c *Cfgo) writeBackup(filename string, original, content []byte) error {
+	if c.backup && len(original) > 0 && !bytes.Equal(original, content) {
+		if err := writeFile(filename+".bak", original, c.fileMode); err != nil {
+			return err
+		}
+	}
+	return writeFile(filename, content, c.fileMode)
 }
 
 type (
@@ -512,6 +900,8 @@ type (
 		title  string
 		single []byte
 		united []byte
+		index  int
+		file   *dropIn // the file it comes from, nil for the default file
 	}
 )
 
@@ -522,7 +912,12 @@ func (s Sections) Len() int {
 
 // Less reports whether the element with
 // index i should sort before the element with index j.
+// The sections keep their order in the original file,
+// and the new ones follow in title order.
 func (s Sections) Less(i, j int) bool {
+	if s[i].index != s[j].index {
+		return s[i].index < s[j].index
+	}
 	return s[i].title < s[j].title
 }
 
@@ -0,0 +1,354 @@
+package cfgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// Codec is the format of config file.
+	Codec interface {
+		// Unmarshal decodes the whole document into sections.
+		Unmarshal(data []byte, sections *map[string]interface{}) error
+		// Marshal encodes a section into a block of the document.
+		Marshal(title string, v interface{}) ([]byte, error)
+		// Render writes the blocks as the whole document.
+		// The extras are the non-automated sections,
+		// they are empty when the file is shared by applications.
+		Render(w io.Writer, blocks, extras [][]byte) error
+	}
+	yamlCodec struct{ divider string }
+	jsonCodec struct{}
+	tomlCodec struct{ divider string }
+
+	// dividedCodec is the codec writing the dividing line above the non-automated sections.
+	dividedCodec interface {
+		withDividingLine(line []byte) Codec
+	}
+)
+
+var (
+	// YAML is the default codec.
+	YAML Codec = yamlCodec{}
+	// JSON codec, the non-automated sections follow the registered ones without dividing line.
+	JSON Codec = jsonCodec{}
+	// TOML codec, the sections that are not tables are placed at the top of the document.
+	TOML Codec = tomlCodec{}
+
+	codecs = map[string]Codec{
+		".yaml": YAML,
+		".yml":  YAML,
+		".json": JSON,
+		".toml": TOML,
+	}
+	codecLock sync.RWMutex
+)
+
+// RegCodec registers codec for the config file extension, such as ".json".
+func RegCodec(ext string, codec Codec) {
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	codecs[strings.ToLower(ext)] = codec
+}
+
+// codecOf returns the codec for the file, YAML by default.
+func codecOf(filename string) Codec {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	if codec, ok := codecs[strings.ToLower(filepath.Ext(filename))]; ok {
+		return codec
+	}
+	return YAML
+}
+
+func (yamlCodec) Unmarshal(data []byte, sections *map[string]interface{}) error {
+	return yaml.Unmarshal(data, sections)
+}
+
+func (yamlCodec) Marshal(title string, v interface{}) ([]byte, error) {
+	single, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var united = bytes.Replace(single, []byte("\r\n"), []byte("\n"), -1)
+	united = bytes.Replace(united, []byte("\n"), indent, -1)
+	return append([]byte(title+":"+string(lineend)+"  "), united[:len(united)-2]...), nil
+}
+
+func (c yamlCodec) withDividingLine(line []byte) Codec {
+	c.divider = string(line)
+	return c
+}
+
+func (c yamlCodec) Render(w io.Writer, blocks, extras [][]byte) error {
+	for i, b := range blocks {
+		if i != 0 {
+			if _, err := w.Write(lineend); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return renderExtras(w, extras, c.divider)
+}
+
+// renderExtras writes the non-automated sections under the dividing line,
+// the default one if divider is empty.
+func renderExtras(w io.Writer, extras [][]byte, divider string) error {
+	for i, b := range extras {
+		if i == 0 {
+			if _, err := w.Write(lineend); err != nil {
+				return err
+			}
+			if divider == "" {
+				divider = string(dividingLine)
+			}
+			if _, err := io.WriteString(w, divider); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(lineend); err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (jsonCodec) Unmarshal(data []byte, sections *map[string]interface{}) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	var m map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		return err
+	}
+	if *sections == nil {
+		*sections = make(map[string]interface{}, len(m))
+	}
+	for k, v := range m {
+		(*sections)[k] = jsonNumbers(v)
+	}
+	return nil
+}
+
+// jsonNumbers converts the json numbers into int64 or float64.
+func jsonNumbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]interface{}:
+		for k, vv := range x {
+			x[k] = jsonNumbers(vv)
+		}
+	case []interface{}:
+		for i, vv := range x {
+			x[i] = jsonNumbers(vv)
+		}
+	}
+	return v
+}
+
+func (jsonCodec) Marshal(title string, v interface{}) ([]byte, error) {
+	g, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	t, err := json.Marshal(title)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(g, "  ", "  ")
+	if err != nil {
+		return nil, err
+	}
+	b = append(append(append([]byte("  "), t...), ": "...), b...)
+	return bytes.Replace(b, []byte("\n"), lineend, -1), nil
+}
+
+func (jsonCodec) Render(w io.Writer, blocks, extras [][]byte) error {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, b := range append(blocks[:len(blocks):len(blocks)], extras...) {
+		if i != 0 {
+			buf.WriteString(",")
+		}
+		buf.Write(lineend)
+		buf.Write(b)
+	}
+	buf.Write(lineend)
+	buf.WriteString("}")
+	buf.Write(lineend)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (tomlCodec) Unmarshal(data []byte, sections *map[string]interface{}) error {
+	_, err := toml.Decode(string(data), sections)
+	return err
+}
+
+func (tomlCodec) Marshal(title string, v interface{}) ([]byte, error) {
+	g, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = toml.NewEncoder(&buf).Encode(map[string]interface{}{title: g})
+	if err != nil {
+		return nil, err
+	}
+	b := bytes.TrimLeft(buf.Bytes(), "\n")
+	return bytes.Replace(b, []byte("\n"), lineend, -1), nil
+}
+
+func (c tomlCodec) withDividingLine(line []byte) Codec {
+	c.divider = string(line)
+	return c
+}
+
+func (c tomlCodec) Render(w io.Writer, blocks, extras [][]byte) error {
+	// the key/value pairs must precede the tables,
+	// otherwise they would belong to the table above.
+	var values, tables, extraTables [][]byte
+	for _, b := range blocks {
+		if bytes.HasPrefix(b, []byte("[")) {
+			tables = append(tables, b)
+		} else {
+			values = append(values, b)
+		}
+	}
+	for _, b := range extras {
+		if bytes.HasPrefix(b, []byte("[")) {
+			extraTables = append(extraTables, b)
+		} else {
+			values = append(values, b)
+		}
+	}
+	for _, b := range values {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	for i, b := range tables {
+		if i != 0 || len(values) > 0 {
+			if _, err := w.Write(lineend); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return renderExtras(w, extraTables, c.divider)
+}
+
+// toGeneric converts the value into maps with string keys, slices and scalars,
+// following the yaml field tags, so that every codec uses the same keys.
+// The values decoded by the codecs are kept, such as the TOML datetimes.
+func toGeneric(v interface{}) (interface{}, error) {
+	if g, ok := decoded(v); ok {
+		return g, nil
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var g interface{}
+	if err = yaml.Unmarshal(b, &g); err != nil {
+		return nil, err
+	}
+	return stringKeys(g), nil
+}
+
+// decoded returns the value with string keys if it only consists of
+// maps, slices, scalars and times, as the codecs decode.
+func decoded(v interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case nil, time.Time:
+		return v, true
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, vv := range x {
+			g, ok := decoded(vv)
+			if !ok {
+				return nil, false
+			}
+			m[k] = g
+		}
+		return m, true
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, vv := range x {
+			g, ok := decoded(vv)
+			if !ok {
+				return nil, false
+			}
+			m[fmt.Sprint(k)] = g
+		}
+		return m, true
+	case []map[string]interface{}:
+		s := make([]map[string]interface{}, len(x))
+		for i, vv := range x {
+			g, ok := decoded(vv)
+			if !ok {
+				return nil, false
+			}
+			s[i] = g.(map[string]interface{})
+		}
+		return s, true
+	case []interface{}:
+		s := make([]interface{}, len(x))
+		for i, vv := range x {
+			g, ok := decoded(vv)
+			if !ok {
+				return nil, false
+			}
+			s[i] = g
+		}
+		return s, true
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v, true
+	}
+	return nil, false
+}
+
+func stringKeys(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, vv := range x {
+			m[fmt.Sprint(k)] = stringKeys(vv)
+		}
+		return m
+	case []interface{}:
+		for i, vv := range x {
+			x[i] = stringKeys(vv)
+		}
+	}
+	return v
+}
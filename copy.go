@@ -0,0 +1,110 @@
+package cfgo
+
+import (
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// deepCopy returns a deep copy of the value,
+// the unexported fields of structs are copied shallowly.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(deepCopy(v.Elem()))
+		return p
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(deepCopy(v.Elem()))
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				cp.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return cp
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return cp
+	case reflect.Array:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return cp
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			cp.SetMapIndex(deepCopy(k), deepCopy(v.MapIndex(k)))
+		}
+		return cp
+	}
+	return v
+}
+
+// assign sets the exported fields of the src struct to the dst struct recursively,
+// so that the unexported state of dst is kept.
+// The fields that are not plain structs are set as a whole.
+func assign(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		if dst.Type().Field(i).PkgPath != "" {
+			continue
+		}
+		if f := dst.Field(i); isPlainStruct(f.Type()) {
+			assign(f, src.Field(i))
+		} else {
+			f.Set(src.Field(i))
+		}
+	}
+}
+
+// keepIgnored sets the fields of dst ignored by yaml (`yaml:"-"`) to the ones of src recursively,
+// since the decoder never touches them, they keep the values set at runtime.
+func keepIgnored(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		sf := dst.Type().Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		if sf.Tag.Get("yaml") == "-" {
+			dst.Field(i).Set(deepCopy(src.Field(i)))
+		} else if isPlainStruct(sf.Type) {
+			keepIgnored(dst.Field(i), src.Field(i))
+		}
+	}
+}
+
+// isPlainStruct reports whether the type is a struct decoded field by field,
+// rather than one with only unexported fields or its own UnmarshalYAML.
+func isPlainStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package cfgo
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// FileEnv is the environment variable that sets the default config file,
+// unless it is set by SetDefaultFile.
+const FileEnv = "CFGO_FILE"
+
+// SetDefaultFile sets the file of the default config, before Default is called.
+func SetDefaultFile(filename string) {
+	defaultRegistry.SetDefaultFile(filename)
+}
+
+// SetDefaultFile sets the file of the default config, before Default is called,
+// or after the default config is closed.
+// The environment variable CFGO_FILE and the search paths are not used then.
+func (r *Registry) SetDefaultFile(filename string) {
+	r.lock.Lock()
+	r.defaultFile, r.defaultSet = filename, true
+	r.lock.Unlock()
+}
+
+// defaultFilename returns the file of the default config, which is set by SetDefaultFile,
+// or CFGO_FILE, or else searched by searchPaths.
+func (r *Registry) defaultFilename() string {
+	if r.defaultSet {
+		return r.defaultFile
+	}
+	if filename := os.Getenv(FileEnv); filename != "" {
+		return filename
+	}
+	paths := searchPaths(r.defaultFile)
+	for _, filename := range paths {
+		if _, err := os.Stat(filename); err == nil {
+			return filename
+		}
+	}
+	// a service started in the root directory creates the file beside the executable
+	if wd, err := os.Getwd(); err == nil && filepath.Dir(wd) == wd && len(paths) > 1 {
+		return paths[1]
+	}
+	return r.defaultFile
+}
+
+// searchPaths returns the places of the default file in order:
+// the working directory, the executable's directory, $XDG_CONFIG_HOME/<app> and /etc/<app>,
+// where <app> is the name of the executable.
+func searchPaths(filename string) []string {
+	paths := []string{filename}
+	exe, err := os.Executable()
+	if err != nil {
+		return paths
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	app := strings.TrimSuffix(filepath.Base(exe), filepath.Ext(exe))
+	base := filepath.Base(filename)
+	paths = append(paths, filepath.Join(filepath.Dir(exe), filename))
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if dir != "" {
+		paths = append(paths, filepath.Join(dir, app, base))
+	}
+	if runtime.GOOS != "windows" {
+		paths = append(paths, filepath.Join("/etc", app, base))
+	}
+	return paths
+}
@@ -0,0 +1,45 @@
+package cfgo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Defaulter is implemented by the config structs that set their own default values.
+// SetDefaults is called before every bind, after the `default` tags are applied,
+// so that a key removed from the file restores its default value.
+//
+// The `default` tag is the default value of a field that is zero,
+// it is parsed as yaml unless the field is a string, e.g. `default:"[a, b]"`.
+type Defaulter interface {
+	SetDefaults()
+}
+
+// setDefaults applies the `default` tags of the zero fields,
+// and then the Defaulter implementations.
+func setDefaults(structPtr interface{}) error {
+	err := walkFields(reflect.ValueOf(structPtr).Elem(), func(f *field) error {
+		if tag, ok := f.sf.Tag.Lookup("default"); ok && f.value.IsZero() {
+			if err := parseValue(f.value, tag); err != nil {
+				return fmt.Errorf("field %s: default %q: %s", strings.Join(f.keys, "."), tag, err.Error())
+			}
+		}
+		if f.isLeaf() {
+			return nil
+		}
+		if v := indirect(f.value); v.IsValid() && v.CanAddr() {
+			if defaulter, ok := v.Addr().Interface().(Defaulter); ok {
+				defaulter.SetDefaults()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if defaulter, ok := structPtr.(Defaulter); ok {
+		defaulter.SetDefaults()
+	}
+	return nil
+}
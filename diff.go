@@ -0,0 +1,94 @@
+package cfgo
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// DiffConfig is a Config that is told what is changed when it is reloaded,
+	// ReloadWithDiff is called instead of Reload.
+	DiffConfig interface {
+		Config
+		ReloadWithDiff(bind BindFunc, diff Diff) error
+	}
+	// Diff is the changed fields of a section, sorted by the paths.
+	Diff []Change
+	// Change is a changed field, Old or New is nil if the field is added or removed.
+	Change struct {
+		Path string // the yaml keys joined by '.'
+		Old  interface{}
+		New  interface{}
+	}
+)
+
+// Has reports whether the field of the path, or any field under it, is changed.
+func (d Diff) Has(path string) bool {
+	for _, c := range d {
+		if c.Path == path || strings.HasPrefix(c.Path, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// callReload calls the Reload method of the struct, or ReloadWithDiff with the changes
+// from the last bound values to single.
+func (c *Cfgo) callReload(section string, structPtr Config, bind BindFunc, single []byte) error {
+	d, ok := structPtr.(DiffConfig)
+	if !ok {
+		return structPtr.Reload(bind)
+	}
+	diff, err := diffOf(c.bound[section], single)
+	if err != nil {
+		return err
+	}
+	return d.ReloadWithDiff(bind, diff)
+}
+
+// diffOf compares the decoded yaml trees of a section.
+func diffOf(old, new []byte) (Diff, error) {
+	var a, b interface{}
+	if err := yaml.Unmarshal(old, &a); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(new, &b); err != nil {
+		return nil, err
+	}
+	var d Diff
+	diffValues("", a, b, &d)
+	sort.Slice(d, func(i, j int) bool {
+		return d[i].Path < d[j].Path
+	})
+	return d, nil
+}
+
+// diffValues compares the mappings recursively, and the other values as a whole.
+func diffValues(path string, a, b interface{}, d *Diff) {
+	am, aok := a.(map[interface{}]interface{})
+	bm, bok := b.(map[interface{}]interface{})
+	if (aok || a == nil) && (bok || b == nil) && (aok || bok) {
+		keys := make(map[interface{}]bool, len(am)+len(bm))
+		for k := range am {
+			keys[k] = true
+		}
+		for k := range bm {
+			keys[k] = true
+		}
+		for k := range keys {
+			p := fmt.Sprint(k)
+			if path != "" {
+				p = path + "." + p
+			}
+			diffValues(p, am[k], bm[k], d)
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*d = append(*d, Change{Path: path, Old: a, New: b})
+	}
+}
@@ -0,0 +1,194 @@
+package cfgo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dropIn is a config file of the directory or glob, which is merged
+// after the default file in lexical order.
+type dropIn struct {
+	filename string
+	codec    Codec
+	content  []byte
+	sections map[string]interface{}
+	blocks   []*block
+	tail     [][]byte
+}
+
+// WithDefaultFile sets the default file when Get is called with a directory or glob,
+// which is read before the other files, and where the registered sections
+// missing from all the files are written.
+// It is 'default.yaml' in the directory by default.
+func WithDefaultFile(filename string) Option {
+	return option(func(c *Cfgo) {
+		c.defaultFile = filename
+	})
+}
+
+// isPattern reports whether the filename is a directory or a glob of config files.
+func isPattern(filename string) bool {
+	if strings.ContainsAny(filename, "*?[") {
+		return true
+	}
+	fi, err := os.Stat(filename)
+	return err == nil && fi.IsDir()
+}
+
+// defaultFileOf returns the default file of the directory or glob.
+func defaultFileOf(pattern string) string {
+	if fi, err := os.Stat(pattern); err == nil && fi.IsDir() {
+		return filepath.Join(pattern, "default.yaml")
+	}
+	ext := filepath.Ext(pattern)
+	if ext == "" || strings.ContainsAny(ext, "*?[") {
+		ext = ".yaml"
+	}
+	return filepath.Join(filepath.Dir(pattern), "default"+ext)
+}
+
+// dropInFiles returns the files of the directory or glob in lexical order,
+// except the default file. The files in a directory must have the extensions of the codecs.
+func (c *Cfgo) dropInFiles() ([]string, error) {
+	var files []string
+	if fi, err := os.Stat(c.pattern); err == nil && fi.IsDir() {
+		infos, err := ioutil.ReadDir(c.pattern)
+		if err != nil {
+			return nil, err
+		}
+		codecLock.RLock()
+		for _, info := range infos {
+			name := info.Name()
+			if _, ok := codecs[strings.ToLower(filepath.Ext(name))]; ok && !info.IsDir() && !strings.HasPrefix(name, ".") {
+				files = append(files, filepath.Join(c.pattern, name))
+			}
+		}
+		codecLock.RUnlock()
+	} else {
+		matches, err := filepath.Glob(c.pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range matches {
+			if fi, err := os.Stat(name); err == nil && !fi.IsDir() {
+				files = append(files, name)
+			}
+		}
+	}
+	sort.Strings(files)
+	kept := files[:0]
+	for _, name := range files {
+		if name != c.filename {
+			kept = append(kept, name)
+		}
+	}
+	return kept, nil
+}
+
+// readDropIns reads the files of the directory or glob after the default file.
+// The sections of a later file override the ones of the same titles,
+// and each section is written back to the last file that has it.
+func (c *Cfgo) readDropIns() error {
+	c.dropIns, c.origins, c.fileSections = nil, nil, nil
+	if c.pattern == "" {
+		return nil
+	}
+	files, err := c.dropInFiles()
+	if err != nil {
+		return err
+	}
+	c.origins = make(map[string]*dropIn)
+	c.fileSections = make(map[string]interface{}, len(c.extraConfigs))
+	for k, v := range c.extraConfigs {
+		c.fileSections[k] = v
+	}
+	for _, filename := range files {
+		d := &dropIn{filename: filename, codec: c.dropInCodec(filename)}
+		if d.content, err = ioutil.ReadFile(filename); err != nil {
+			return err
+		}
+		if err = d.codec.Unmarshal(d.content, &d.sections); err != nil {
+			return parseError(filename, d.content, err)
+		}
+		d.blocks, d.tail = c.splitBlocks(d.codec, d.content)
+		c.readOrder(filename, d.blocks)
+		for k, v := range d.sections {
+			c.extraConfigs[k] = v
+			c.origins[k] = d
+		}
+		c.dropIns = append(c.dropIns, d)
+	}
+	return nil
+}
+
+// dropInCodec returns the codec of the drop-in file by its extension,
+// the codec of the default file if they have the same extension.
+func (c *Cfgo) dropInCodec(filename string) Codec {
+	if strings.EqualFold(filepath.Ext(filename), filepath.Ext(c.filename)) {
+		return c.codec
+	}
+	codec := codecOf(filename)
+	if d, ok := codec.(dividedCodec); ok && c.dividingLine != nil {
+		codec = d.withDividingLine(c.dividingLine)
+	}
+	return codec
+}
+
+// fileCodec returns the codec of the file, the default file if d is nil.
+func (c *Cfgo) fileCodec(d *dropIn) Codec {
+	if d == nil {
+		return c.codec
+	}
+	return d.codec
+}
+
+// keepShadowed creates the sections of the files, which are overridden by the later files,
+// so that they are written back unchanged.
+func (c *Cfgo) keepShadowed() error {
+	c.shadowed = nil
+	if c.pattern == "" {
+		return nil
+	}
+	for k, v := range c.fileSections {
+		if c.origins[k] != nil {
+			section, err := c.createSection(k, v, nil)
+			if err != nil {
+				return err
+			}
+			c.shadowed = append(c.shadowed, section)
+		}
+	}
+	for _, d := range c.dropIns {
+		for k, v := range d.sections {
+			if c.origins[k] != d {
+				section, err := c.createSection(k, v, d)
+				if err != nil {
+					return err
+				}
+				c.shadowed = append(c.shadowed, section)
+			}
+		}
+	}
+	return nil
+}
+
+// writeDropIns writes the sections back to the files they come from.
+func (c *Cfgo) writeDropIns() error {
+	for _, d := range c.dropIns {
+		content, err := c.render(d)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(content, d.content) {
+			if err = c.writeBackup(d.filename, d.content, content); err != nil {
+				return err
+			}
+		}
+		c.writtenOrder(d.filename, content)
+	}
+	return nil
+}
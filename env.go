@@ -0,0 +1,80 @@
+package cfgo
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// EnvKeyFunc maps the yaml keys of a registered section field to the environment variable name.
+type EnvKeyFunc func(prefix, section string, keys []string) string
+
+// DefaultEnvKey joins the prefix, section and keys with '_' in upper case,
+// the other characters that are not letters or digits are replaced by '_'.
+// e.g. APP_SECTION1_B for the key 'b' of the section 'section1' with the prefix 'APP'.
+func DefaultEnvKey(prefix, section string, keys []string) string {
+	name := strings.Join(append([]string{prefix, section}, keys...), "_")
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// SetEnvPrefix sets the prefix of the environment variables that override the default config.
+func SetEnvPrefix(prefix string) {
+	Default().SetEnvPrefix(prefix)
+}
+
+// SetEnvKeyFunc sets the environment variable names of the default config.
+func SetEnvKeyFunc(fn EnvKeyFunc) {
+	Default().SetEnvKeyFunc(fn)
+}
+
+// SetEnvPrefix sets the prefix of the environment variables that override
+// the fields of the registered sections, it takes effect on the next reload.
+// An empty prefix disables them, except the fields tagged with `env:"NAME"`.
+// The overrides are not written to the config file.
+func (c *Cfgo) SetEnvPrefix(prefix string) {
+	c.lc.Lock()
+	c.envPrefix = prefix
+	c.lc.Unlock()
+}
+
+// SetEnvKeyFunc sets the environment variable names of the fields, DefaultEnvKey by default.
+func (c *Cfgo) SetEnvKeyFunc(fn EnvKeyFunc) {
+	if fn == nil {
+		fn = DefaultEnvKey
+	}
+	c.lc.Lock()
+	c.envKeyFunc = fn
+	c.lc.Unlock()
+}
+
+// applyEnv overrides the fields of the section with the environment variables.
+func (c *Cfgo) applyEnv(section string, root reflect.Value, o *overrides) error {
+	return walkFields(root, func(f *field) error {
+		name := f.sf.Tag.Get("env")
+		if name == "" {
+			if c.envPrefix == "" || !f.isLeaf() {
+				return nil
+			}
+			name = c.envKeyFunc(c.envPrefix, section, f.keys)
+		}
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		v := reflect.New(f.sf.Type).Elem()
+		if err := parseValue(v, s); err != nil {
+			return fmt.Errorf("env %s: %s", name, err.Error())
+		}
+		o.set(f, v)
+		return nil
+	})
+}
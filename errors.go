@@ -0,0 +1,120 @@
+package cfgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The errors can be checked by errors.Is.
+var (
+	ErrNotStructPtr     = errors.New("not a struct pointer")
+	ErrDuplicateSection = errors.New("duplicate section")
+	ErrSectionNotFound  = errors.New("section not found")
+	ErrReadOnly         = errors.New("read-only config file")
+)
+
+// SectionError is the error of a section of the config file,
+// such as a decoding, validation or Reload error.
+type SectionError struct {
+	File    string
+	Section string
+	Err     error
+}
+
+func (e *SectionError) Error() string {
+	return "section " + e.Section + ": " + e.Err.Error()
+}
+
+func (e *SectionError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is the syntax error of the config file.
+// Line and Column start at 1, and they are 0 if unknown.
+// The YAML and TOML parsers report the line only.
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	where := e.File
+	if e.Line > 0 {
+		where += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			where += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	return where + ": " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is the errors of several sections or files.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	s := make([]string, len(m))
+	for i, err := range m {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, string(lineend))
+}
+
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+var (
+	lineRegexp = regexp.MustCompile(`\bline (\d+)\b`)
+	// yamlParserProblems are the syntax errors of yaml.v2 counting the lines from 0,
+	// the others reported by its scanner count from 1.
+	yamlParserProblems = regexp.MustCompile(`^yaml: line \d+: (did not find expected (<stream-start>|<document start>|node content|'-' indicator|key|',' or '\]'|',' or '\}')|found (undefined tag handle|duplicate %YAML directive|incompatible YAML document|duplicate %TAG directive))$`)
+)
+
+// parseError returns the ParseError of the file content,
+// the position is taken from the error of the codec.
+func parseError(filename string, data []byte, err error) *ParseError {
+	e := &ParseError{File: filename, Err: err}
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	} else if errors.As(err, &typeErr) {
+		offset = typeErr.Offset
+	}
+	if offset >= 0 && offset <= int64(len(data)) {
+		before := data[:offset]
+		e.Line = bytes.Count(before, lineend) + 1
+		e.Column = int(offset) - (bytes.LastIndex(before, lineend) + 1)
+		if e.Column == 0 {
+			e.Column = 1
+		}
+		return e
+	}
+	if m := lineRegexp.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+		if yamlParserProblems.MatchString(err.Error()) {
+			e.Line++
+		}
+	}
+	return e
+}
+
+// sectionError wraps err with the section, unless it is a SectionError already.
+func (c *Cfgo) sectionError(section string, err error) error {
+	var e *SectionError
+	if errors.As(err, &e) {
+		return err
+	}
+	return &SectionError{File: c.filename, Section: section, Err: err}
+}
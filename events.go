@@ -0,0 +1,221 @@
+package cfgo
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// EventKind is the kind of a change of a config section.
+type EventKind int
+
+const (
+	// EventRegistered is sent when a section is registered.
+	EventRegistered EventKind = iota + 1
+	// EventReloaded is sent when a registered section is changed.
+	EventReloaded
+	// EventRemoved is sent when a section is removed from the file, or unregistered,
+	// New is the non-automated section kept after it is unregistered.
+	EventRemoved
+	// EventExtraChanged is sent when a non-automated section is added or changed.
+	EventExtraChanged
+)
+
+var eventKinds = map[EventKind]string{
+	EventRegistered:   "registered",
+	EventReloaded:     "reloaded",
+	EventRemoved:      "removed",
+	EventExtraChanged: "extra section changed",
+}
+
+func (k EventKind) String() string {
+	return eventKinds[k]
+}
+
+// Event is a change of a config section, Old and New are the yaml of the section
+// before and after the change, nil if it does not exist.
+type Event struct {
+	Section string
+	Kind    EventKind
+	Old     []byte
+	New     []byte
+}
+
+// Subscribe subscribes the changes of the default config section.
+func Subscribe(section string) (<-chan Event, func()) {
+	return Default().Subscribe(section)
+}
+
+// OnChange calls fn for every change of the default config sections.
+func OnChange(fn func(Event)) {
+	Default().OnChange(fn)
+}
+
+// Subscribe returns the channel of the changes of the section, all the sections if it is empty.
+// The events are sent in order after the file is synchronized successfully,
+// they are queued if the channel is not received in time.
+// The cancel function stops the subscription and closes the channel.
+func (c *Cfgo) Subscribe(section string) (<-chan Event, func()) {
+	ch := make(chan Event)
+	s := c.subscribe(section, nil)
+	s.fn = func(e Event) {
+		select {
+		case ch <- e:
+		case <-s.done:
+		}
+	}
+	s.closeFn = func() { close(ch) }
+	go s.run()
+	return ch, func() { c.unsubscribe(s) }
+}
+
+// OnChange calls fn for every change of the sections, in order and in a goroutine,
+// so that fn may use the config.
+func (c *Cfgo) OnChange(fn func(Event)) {
+	s := c.subscribe("", fn)
+	go s.run()
+}
+
+type (
+	// subscriber receives the events by a goroutine.
+	subscriber struct {
+		section string
+		fn      func(Event)
+		lock    sync.Mutex
+		queue   []Event
+		notify  chan struct{}
+		done    chan struct{}
+		exited  chan struct{}
+		closeFn func()
+		once    sync.Once
+	}
+	// published is the state of a section that the last events are sent for.
+	published struct {
+		single     []byte
+		registered bool
+	}
+)
+
+func (c *Cfgo) subscribe(section string, fn func(Event)) *subscriber {
+	s := &subscriber{
+		section: section,
+		fn:      fn,
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	c.subLock.Lock()
+	c.subscribers = append(c.subscribers, s)
+	c.subLock.Unlock()
+	return s
+}
+
+// unsubscribe removes the subscriber and waits for its goroutine to exit.
+func (c *Cfgo) unsubscribe(s *subscriber) {
+	s.once.Do(func() {
+		c.subLock.Lock()
+		for i, x := range c.subscribers {
+			if x == s {
+				c.subscribers = append(c.subscribers[:i:i], c.subscribers[i+1:]...)
+				break
+			}
+		}
+		c.subLock.Unlock()
+		close(s.done)
+		<-s.exited
+		if s.closeFn != nil {
+			s.closeFn()
+		}
+	})
+}
+
+func (s *subscriber) push(e Event) {
+	s.lock.Lock()
+	s.queue = append(s.queue, e)
+	s.lock.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) run() {
+	defer close(s.exited)
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.notify:
+		}
+		s.lock.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.lock.Unlock()
+		for _, e := range queue {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.fn(e)
+		}
+	}
+}
+
+// publish sends the events of the changes since the last successful synchronization.
+func (c *Cfgo) publish() {
+	current := make(map[string]published, len(c.regSections)+len(c.extraSections))
+	for _, s := range c.regSections {
+		current[s.title] = published{single: s.single, registered: true}
+	}
+	for _, s := range c.extraSections {
+		current[s.title] = published{single: s.single}
+	}
+	var events []Event
+	for k, cur := range current {
+		old, ok := c.published[k]
+		e := Event{Section: k, New: cur.single}
+		if ok {
+			e.Old = old.single
+		}
+		switch {
+		case cur.registered && (!ok || !old.registered):
+			e.Kind = EventRegistered
+		case ok && old.registered == cur.registered && bytes.Equal(old.single, cur.single):
+			continue
+		case cur.registered:
+			e.Kind = EventReloaded
+		case ok && old.registered:
+			e.Kind = EventRemoved
+		default:
+			e.Kind = EventExtraChanged
+		}
+		events = append(events, e)
+	}
+	for k, old := range c.published {
+		if _, ok := current[k]; !ok {
+			events = append(events, Event{Section: k, Kind: EventRemoved, Old: old.single})
+		}
+	}
+	c.published = current
+	if len(events) == 0 {
+		return
+	}
+	sortEvents(events)
+	c.subLock.Lock()
+	defer c.subLock.Unlock()
+	for _, s := range c.subscribers {
+		for _, e := range events {
+			if s.section == "" || s.section == e.Section {
+				s.push(e)
+			}
+		}
+	}
+}
+
+// sortEvents sorts the events by the section titles.
+func sortEvents(events []Event) {
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Section < events[j].Section
+	})
+}
@@ -0,0 +1,103 @@
+package cfgo
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// BindFlags defines the flags of the default config sections.
+func BindFlags(fs *flag.FlagSet) {
+	Default().BindFlags(fs)
+}
+
+// BindFlags defines a flag on fs (flag.CommandLine if nil) for every field
+// of the registered sections, named by the section and the yaml keys, such as
+// '--section1.b=5'. The sections registered later are not included.
+// The flag values override the file values and the environment variables,
+// they are not written to the config file.
+// The values take effect on the next reload, so call Reload after parsing the flags.
+func (c *Cfgo) BindFlags(fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	c.lc.RLock()
+	defer c.lc.RUnlock()
+	sections := make([]string, 0, len(c.regConfigs))
+	for section := range c.regConfigs {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	for _, section := range sections {
+		structPtr := c.regConfigs[section]
+		walkFields(reflect.ValueOf(structPtr).Elem(), func(f *field) error {
+			if !f.isLeaf() {
+				return nil
+			}
+			name := section + "." + strings.Join(f.keys, ".")
+			fs.Var(&flagValue{
+				c:       c,
+				section: section,
+				key:     strings.Join(f.keys, "."),
+				typ:     f.sf.Type,
+				def:     fmt.Sprint(f.value.Interface()),
+			}, name, "config "+name)
+			return nil
+		})
+	}
+}
+
+// flagValue is the flag.Value of a config field.
+type flagValue struct {
+	c       *Cfgo
+	section string
+	key     string
+	typ     reflect.Type
+	def     string
+}
+
+func (v *flagValue) String() string {
+	return v.def
+}
+
+func (v *flagValue) Set(s string) error {
+	if err := parseValue(reflect.New(v.typ).Elem(), s); err != nil {
+		return err
+	}
+	v.c.lc.Lock()
+	defer v.c.lc.Unlock()
+	values := v.c.flagValues[v.section]
+	if values == nil {
+		values = make(map[string]string)
+		v.c.flagValues[v.section] = values
+	}
+	values[v.key] = s
+	return nil
+}
+
+// IsBoolFlag allows '--section.flag' without value for the bool fields.
+func (v *flagValue) IsBoolFlag() bool {
+	return v.typ != nil && v.typ.Kind() == reflect.Bool
+}
+
+// applyFlags overrides the fields of the section with the flag values.
+func (c *Cfgo) applyFlags(section string, root reflect.Value, o *overrides) error {
+	values := c.flagValues[section]
+	if len(values) == 0 {
+		return nil
+	}
+	return walkFields(root, func(f *field) error {
+		s, ok := values[strings.Join(f.keys, ".")]
+		if !ok || !f.isLeaf() {
+			return nil
+		}
+		v := reflect.New(f.sf.Type).Elem()
+		if err := parseValue(v, s); err != nil {
+			return fmt.Errorf("flag %s: %s", strings.Join(f.keys, "."), err.Error())
+		}
+		o.set(f, v)
+		return nil
+	})
+}
@@ -0,0 +1,258 @@
+package cfgo
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// maxInterpolation is the depth limit of the nested references.
+const maxInterpolation = 10
+
+// placeholderRegexp matches ${NAME}, ${NAME:-default}, ${section.key} and the escaped $${...}.
+var placeholderRegexp = regexp.MustCompile(`\$?\$\{([^{}]*)\}`)
+
+// interpolator expands the placeholders in the values of the registered sections:
+//
+//	${NAME}               The environment variable, it is an error if it is not set.
+//	${NAME:-default}      The environment variable, or the default if it is not set or empty.
+//	${section.key}        The value of another section in the files, the keys are separated by '.'.
+//	$${...}               The text '${...}' without expanding.
+//
+// A value that is a single placeholder takes the type of the expanded value,
+// such as an int for 'port: ${PORT}'.
+type interpolator struct {
+	sections map[string]interface{}
+}
+
+func newInterpolator(sections map[string]interface{}) *interpolator {
+	in := &interpolator{sections: make(map[string]interface{}, len(sections))}
+	for k, v := range sections {
+		in.sections[k] = v
+	}
+	return in
+}
+
+// expand returns a copy of the yaml value with the placeholders expanded.
+func (in *interpolator) expand(v interface{}, depth int) (interface{}, error) {
+	if depth > maxInterpolation {
+		return nil, fmt.Errorf("interpolation is too deep or circular")
+	}
+	switch x := v.(type) {
+	case string:
+		return in.expandString(x, depth)
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(x))
+		for k, vv := range x {
+			ev, err := in.expand(vv, depth)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = ev
+		}
+		return m, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, vv := range x {
+			ev, err := in.expand(vv, depth)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = ev
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(x))
+		for i, vv := range x {
+			ev, err := in.expand(vv, depth)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = ev
+		}
+		return s, nil
+	}
+	return v, nil
+}
+
+func (in *interpolator) expandString(s string, depth int) (interface{}, error) {
+	locs := placeholderRegexp.FindAllStringSubmatchIndex(s, -1)
+	if len(locs) == 0 {
+		return s, nil
+	}
+	// a single placeholder keeps the type of the value
+	if len(locs) == 1 && locs[0][0] == 0 && locs[0][1] == len(s) && !strings.HasPrefix(s, "$$") {
+		return in.resolve(s[locs[0][2]:locs[0][3]], depth)
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		b.WriteString(s[last:loc[0]])
+		last = loc[1]
+		if strings.HasPrefix(s[loc[0]:], "$$") {
+			b.WriteString(s[loc[0]+1 : loc[1]])
+			continue
+		}
+		v, err := in.resolve(s[loc[2]:loc[3]], depth)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			fmt.Fprint(&b, v)
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// resolve returns the value of the placeholder expression.
+func (in *interpolator) resolve(expr string, depth int) (interface{}, error) {
+	name, def, hasDef := expr, "", false
+	if i := strings.Index(expr, ":-"); i >= 0 {
+		name, def, hasDef = expr[:i], expr[i+2:], true
+	}
+	name = strings.TrimSpace(name)
+	if strings.Contains(name, ".") {
+		if v, ok := in.lookup(strings.Split(name, ".")); ok {
+			return in.expand(v, depth+1)
+		}
+		if hasDef {
+			return in.expandString(def, depth+1)
+		}
+		return nil, fmt.Errorf("interpolation: no such key: %s", name)
+	}
+	if s, ok := os.LookupEnv(name); ok && (s != "" || !hasDef) {
+		return scalarOf(s), nil
+	}
+	if hasDef {
+		return in.expandString(def, depth+1)
+	}
+	return nil, fmt.Errorf("interpolation: environment variable %s is not set", name)
+}
+
+// lookup returns the value of the keys in the sections.
+func (in *interpolator) lookup(keys []string) (interface{}, bool) {
+	v, ok := in.sections[keys[0]]
+	for _, k := range keys[1:] {
+		if !ok {
+			return nil, false
+		}
+		switch x := v.(type) {
+		case map[interface{}]interface{}:
+			v, ok = x[k]
+		case map[string]interface{}:
+			v, ok = x[k]
+		default:
+			return nil, false
+		}
+	}
+	return v, ok
+}
+
+// scalarOf parses the text as a yaml scalar, or returns it as a string.
+func scalarOf(s string) interface{} {
+	var v interface{}
+	if yaml.Unmarshal([]byte(s), &v) != nil || v == nil {
+		return s
+	}
+	switch v.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return s
+	}
+	return v
+}
+
+// withPlaceholders returns the value to be written with the placeholders of the file,
+// for the values that are still the same as the expanded ones.
+func withPlaceholders(v interface{}, raw, expanded []byte) (interface{}, error) {
+	if raw == nil || !placeholderRegexp.Match(raw) {
+		return v, nil
+	}
+	var rawValue, expandedValue interface{}
+	if err := yaml.Unmarshal(raw, &rawValue); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(expanded, &expandedValue); err != nil {
+		return nil, err
+	}
+	paths := placeholderPaths(rawValue, nil)
+	if len(paths) == 0 {
+		return v, nil
+	}
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var current interface{}
+	if err = yaml.Unmarshal(out, &current); err != nil {
+		return nil, err
+	}
+	// keep the order of the keys
+	result := current
+	if _, ok := current.(map[interface{}]interface{}); ok {
+		var ms yaml.MapSlice
+		if err = yaml.Unmarshal(out, &ms); err != nil {
+			return nil, err
+		}
+		result = ms
+	}
+	for _, p := range paths {
+		cv, ok := lookupPath(current, p)
+		if !ok {
+			continue
+		}
+		if ev, ok := lookupPath(expandedValue, p); ok && reflect.DeepEqual(cv, ev) {
+			rv, _ := lookupPath(rawValue, p)
+			result = replacePath(result, p, rv)
+		}
+	}
+	return result, nil
+}
+
+// placeholderPaths returns the paths of the strings with placeholders in the yaml value.
+func placeholderPaths(v interface{}, path []interface{}) [][]interface{} {
+	var paths [][]interface{}
+	switch x := v.(type) {
+	case string:
+		if placeholderRegexp.MatchString(x) {
+			paths = append(paths, path)
+		}
+	case map[interface{}]interface{}:
+		for k, vv := range x {
+			paths = append(paths, placeholderPaths(vv, append(path[:len(path):len(path)], k))...)
+		}
+	case []interface{}:
+		for i, vv := range x {
+			paths = append(paths, placeholderPaths(vv, append(path[:len(path):len(path)], i))...)
+		}
+	}
+	return paths
+}
+
+// replacePath sets the value at the path of the existing mappings and sequences.
+func replacePath(v interface{}, path []interface{}, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	switch x := v.(type) {
+	case yaml.MapSlice:
+		for i, item := range x {
+			if item.Key == path[0] {
+				x[i].Value = replacePath(item.Value, path[1:], value)
+			}
+		}
+	case map[interface{}]interface{}:
+		if vv, ok := x[path[0]]; ok {
+			x[path[0]] = replacePath(vv, path[1:], value)
+		}
+	case []interface{}:
+		if i, ok := path[0].(int); ok && i < len(x) {
+			x[i] = replacePath(x[i], path[1:], value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,10 @@
+package cfgo
+
+import "time"
+
+// DefaultLockTimeout is the default timeout of locking the shared config file.
+const DefaultLockTimeout = 10 * time.Second
+
+// maxMerges is the maximum times of merging again,
+// when the file is changed between reading and writing.
+const maxMerges = 3
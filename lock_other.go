@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package cfgo
+
+import "time"
+
+// lockFile is not supported, only the merging again protects the shared file.
+func lockFile(filename string, timeout time.Duration) (unlock func(), err error) {
+	return func() {}, nil
+}
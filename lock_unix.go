@@ -0,0 +1,41 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package cfgo
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// lockFile takes the advisory lock on '<filename>.lock',
+// which is kept when the config file is replaced by renaming.
+func lockFile(filename string, timeout time.Duration) (unlock func(), err error) {
+	name := filename + ".lock"
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	fd := int(file.Fd())
+	deadline := time.Now().Add(timeout)
+	for {
+		err = syscall.Flock(fd, syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return func() {
+				syscall.Flock(fd, syscall.LOCK_UN)
+				file.Close()
+			}, nil
+		}
+		if err != syscall.EWOULDBLOCK && err != syscall.EINTR {
+			file.Close()
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("lock %s: timeout after %s", name, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
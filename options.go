@@ -0,0 +1,152 @@
+package cfgo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ReadOnlyEnv is the environment variable that makes all configs read-only if it is true.
+const ReadOnlyEnv = "CFGO_READONLY"
+
+type (
+	// Option is an option of Get, such as WithFileMode(0600).
+	// A bool is accepted as WithAppsShare for compatibility.
+	Option interface{}
+
+	option func(*Cfgo)
+)
+
+// WithAppsShare allows other applications to share the configuration file.
+func WithAppsShare(allow bool) Option {
+	return option(func(c *Cfgo) {
+		c.allowAppsShare = allow
+	})
+}
+
+// WithFileMode sets the mode of the config file if it is created, 0666 before umask by default.
+// The mode of an existing file is kept.
+func WithFileMode(mode os.FileMode) Option {
+	return option(func(c *Cfgo) {
+		c.fileMode = mode
+	})
+}
+
+// WithCodec sets the codec of the config file instead of the one selected by its extension.
+func WithCodec(codec Codec) Option {
+	return option(func(c *Cfgo) {
+		if codec != nil {
+			c.codec = codec
+		}
+	})
+}
+
+// WithWatch watches the config file after it is loaded, see Cfgo.Watch.
+func WithWatch(errFunc func(error)) Option {
+	return option(func(c *Cfgo) {
+		c.watch, c.watchErrFunc = true, errFunc
+	})
+}
+
+// WithWriteBack sets whether the file is rewritten with the registered sections
+// when they are registered or reloaded, true by default.
+// Update and Save always write the file.
+func WithWriteBack(enable bool) Option {
+	return option(func(c *Cfgo) {
+		c.writeBack = enable
+	})
+}
+
+// WithReadOnly never creates or writes the config file, the sections are
+// loaded and bound as usual, and Content returns what would be written.
+// Update and Save return ErrReadOnly.
+// It is enabled for all configs if the environment variable CFGO_READONLY is true.
+func WithReadOnly(readOnly bool) Option {
+	return option(func(c *Cfgo) {
+		c.readOnly = readOnly
+	})
+}
+
+// WithUnknownKeyMode sets the handling of the unknown keys, see Cfgo.SetUnknownKeyMode.
+func WithUnknownKeyMode(mode UnknownKeyMode) Option {
+	return option(func(c *Cfgo) {
+		c.unknownKeyMode = mode
+	})
+}
+
+// WithDividingLine sets the comment line above the non-automated sections,
+// for the YAML and TOML codecs.
+func WithDividingLine(text string) Option {
+	return option(func(c *Cfgo) {
+		c.dividingLine = append([]byte(text), lineend...)
+	})
+}
+
+// WithLockTimeout sets the timeout of locking the shared config file.
+func WithLockTimeout(timeout time.Duration) Option {
+	return option(func(c *Cfgo) {
+		c.lockTimeout = timeout
+	})
+}
+
+// WithBackup keeps the previous version of the config file, see Cfgo.KeepBackup.
+func WithBackup(keep bool) Option {
+	return option(func(c *Cfgo) {
+		c.backup = keep
+	})
+}
+
+// WithEnvPrefix sets the prefix of the environment variables, see Cfgo.SetEnvPrefix.
+func WithEnvPrefix(prefix string) Option {
+	return option(func(c *Cfgo) {
+		c.envPrefix = prefix
+	})
+}
+
+// apply applies the options to the new Cfgo.
+func (c *Cfgo) apply(opts []Option) error {
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case option:
+			o(c)
+		case bool:
+			c.allowAppsShare = o
+		case nil:
+		default:
+			return fmt.Errorf("invalid option: %T", opt)
+		}
+	}
+	if isPattern(c.filename) {
+		c.pattern = c.filename
+		if c.defaultFile == "" {
+			c.defaultFile = defaultFileOf(c.pattern)
+		}
+		abs, err := filepath.Abs(c.defaultFile)
+		if err != nil {
+			return err
+		}
+		c.filename = abs
+	}
+	if c.codec == nil {
+		c.codec = codecOf(c.filename)
+	}
+	if !c.profileSet {
+		c.profile = os.Getenv(ProfileEnv)
+	}
+	if c.writeBackFile != "" {
+		abs, err := filepath.Abs(c.writeBackFile)
+		if err != nil {
+			return err
+		}
+		c.writeBackFile = abs
+	}
+	if readOnly, err := strconv.ParseBool(os.Getenv(ReadOnlyEnv)); err == nil && readOnly {
+		c.readOnly = true
+	}
+	if d, ok := c.codec.(dividedCodec); ok && c.dividingLine != nil {
+		c.codec = d.withDividingLine(c.dividingLine)
+	}
+	return nil
+}
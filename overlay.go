@@ -0,0 +1,164 @@
+package cfgo
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// field is an exported field of a config struct.
+	field struct {
+		keys  []string // yaml keys from the section
+		index []int    // field indexes from the struct
+		sf    reflect.StructField
+		value reflect.Value
+	}
+	// override is a field overridden at runtime,
+	// the value from the file is kept for writing back.
+	override struct {
+		index []int
+		value reflect.Value
+	}
+	overrides []*override
+)
+
+// walkFields calls fn for the exported fields of the struct recursively,
+// following the yaml keys.
+func walkFields(v reflect.Value, fn func(*field) error) error {
+	return walk(v, nil, nil, fn)
+}
+
+func walk(v reflect.Value, keys []string, index []int, fn func(*field) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		key, opts := sf.Tag.Get("yaml"), ""
+		if j := strings.Index(key, ","); j >= 0 {
+			key, opts = key[:j], key[j+1:]
+		}
+		if key == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		idx := append(index[:len(index):len(index)], i)
+		if strings.Contains(","+opts+",", ",inline,") {
+			if fv.Kind() == reflect.Struct {
+				if err := walk(fv, keys, idx, fn); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(sf.Name)
+		}
+		f := &field{
+			keys:  append(keys[:len(keys):len(keys)], key),
+			index: idx,
+			sf:    sf,
+			value: fv,
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+		if fv = indirect(fv); fv.IsValid() && isPlainStruct(fv.Type()) {
+			if err := walk(fv, f.keys, idx, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indirect dereferences the pointers, it returns an invalid value for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isLeaf reports whether the field is not a struct that is walked into.
+func (f *field) isLeaf() bool {
+	t := f.sf.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return !isPlainStruct(t)
+}
+
+// parseValue sets the text to the value, the strings are taken literally
+// and the others are parsed as yaml.
+func parseValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(s)
+		return nil
+	}
+	p := reflect.New(v.Type())
+	if err := yaml.Unmarshal([]byte(s), p.Interface()); err != nil {
+		return err
+	}
+	v.Set(p.Elem())
+	return nil
+}
+
+// set sets the runtime value to the field, and keeps the value from the file.
+func (o *overrides) set(f *field, v reflect.Value) {
+	for _, x := range *o {
+		if reflect.DeepEqual(x.index, f.index) {
+			f.value.Set(v)
+			return
+		}
+	}
+	old := reflect.New(f.value.Type()).Elem()
+	old.Set(f.value)
+	*o = append(*o, &override{index: f.index, value: old})
+	f.value.Set(v)
+}
+
+// persisted returns the struct to be written to the file,
+// which is a copy without the runtime overrides if there are any.
+func (c *Cfgo) persisted(section string, v interface{}) interface{} {
+	overrides := c.overrides[section]
+	if len(overrides) == 0 {
+		return v
+	}
+	rv := reflect.ValueOf(v).Elem()
+	for _, o := range overrides {
+		rv = patchCopy(rv, o.index, o.value)
+	}
+	p := reflect.New(rv.Type())
+	p.Elem().Set(rv)
+	return p.Interface()
+}
+
+// patchCopy returns a copy of the struct with the field set,
+// the structs pointed to on the path are copied too.
+func patchCopy(v reflect.Value, index []int, value reflect.Value) reflect.Value {
+	cp := reflect.New(v.Type()).Elem()
+	cp.Set(v)
+	f := cp.Field(index[0])
+	if len(index) == 1 {
+		f.Set(value)
+		return cp
+	}
+	if f.Kind() != reflect.Ptr {
+		f.Set(patchCopy(f, index[1:], value))
+		return cp
+	}
+	if f.IsNil() {
+		return cp
+	}
+	p := reflect.New(f.Type().Elem())
+	p.Elem().Set(patchCopy(f.Elem(), index[1:], value))
+	f.Set(p)
+	return cp
+}
@@ -0,0 +1,199 @@
+package cfgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ProfileEnv is the environment variable that selects the profile,
+// unless it is set by WithProfile.
+const ProfileEnv = "CFGO_PROFILE"
+
+// MergeStrategy is the way the profile overlay is merged into the base file.
+type MergeStrategy int
+
+const (
+	// MergeDeep merges the mappings recursively and replaces the lists, by default.
+	MergeDeep MergeStrategy = iota
+	// MergeAppend merges the mappings recursively and appends the lists.
+	MergeAppend
+	// MergeReplace replaces the whole sections.
+	MergeReplace
+)
+
+// WithProfile selects the profile overlay, such as 'config/config.production.yaml'
+// for the profile 'production' of 'config/config.yaml', which is merged into
+// the registered sections before they are bound. The overlay is not written,
+// and the values from it are not written to the base file.
+// A missing overlay file is taken as empty.
+func WithProfile(profile string) Option {
+	return option(func(c *Cfgo) {
+		c.profile, c.profileSet = profile, true
+	})
+}
+
+// WithMergeStrategy sets the way the profile overlay is merged, MergeDeep by default.
+func WithMergeStrategy(strategy MergeStrategy) Option {
+	return option(func(c *Cfgo) {
+		c.mergeStrategy = strategy
+	})
+}
+
+// WithWriteBackFile writes the rendered config to the file instead of the base file,
+// which is only read then.
+func WithWriteBackFile(filename string) Option {
+	return option(func(c *Cfgo) {
+		c.writeBackFile = filename
+	})
+}
+
+// writeBackTarget returns the file that the rendered config is written to.
+func (c *Cfgo) writeBackTarget() string {
+	if c.writeBackFile != "" {
+		return c.writeBackFile
+	}
+	return c.filename
+}
+
+// ProfileFilename returns the overlay file name of the profile, or empty if no profile is selected.
+func (c *Cfgo) ProfileFilename() string {
+	if c.profile == "" {
+		return ""
+	}
+	ext := filepath.Ext(c.filename)
+	return strings.TrimSuffix(c.filename, ext) + "." + c.profile + ext
+}
+
+// readOverlay reads the sections of the profile overlay file,
+// the placeholders are expanded by in.
+func (c *Cfgo) readOverlay(in *interpolator) error {
+	c.overlays = nil
+	filename := c.ProfileFilename()
+	if filename == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var sections map[string]interface{}
+	if err = c.codec.Unmarshal(data, &sections); err != nil {
+		return parseError(filename, data, err)
+	}
+	c.overlays = make(map[string][]byte, len(sections))
+	for k, v := range sections {
+		if v, err = in.expand(v, 0); err != nil {
+			return c.sectionError(k, err)
+		}
+		if c.overlays[k], err = yaml.Marshal(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overlayExtras merges the profile overlay into the non-automated sections
+// for GetSection and BindSection, the sections written are not changed.
+func (c *Cfgo) overlayExtras() error {
+	c.overlaid = nil
+	for k, ob := range c.overlays {
+		if _, ok := c.regConfigs[k]; ok {
+			continue
+		}
+		var base, overlay interface{}
+		if v, ok := c.extraConfigs[k]; ok {
+			b, err := yaml.Marshal(v)
+			if err != nil {
+				return err
+			}
+			if err = yaml.Unmarshal(b, &base); err != nil {
+				return err
+			}
+		}
+		if err := yaml.Unmarshal(ob, &overlay); err != nil {
+			return err
+		}
+		if c.overlaid == nil {
+			c.overlaid = make(map[string]interface{}, len(c.overlays))
+		}
+		c.overlaid[k] = merge(base, overlay, c.mergeStrategy)
+	}
+	return nil
+}
+
+// applyOverlay overrides the fields of the section with the values merged
+// from the profile overlay, so that the values from the base file are written back.
+func (c *Cfgo) applyOverlay(section string, root reflect.Value, b []byte, o *overrides) error {
+	ob, ok := c.overlays[section]
+	if !ok {
+		return nil
+	}
+	if err := c.checkUnknownKeys(root.Type(), ob); err != nil {
+		return err
+	}
+	var base, overlay interface{}
+	if err := yaml.Unmarshal(b, &base); err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(ob, &overlay); err != nil {
+		return err
+	}
+	merged, err := yaml.Marshal(merge(base, overlay, c.mergeStrategy))
+	if err != nil {
+		return err
+	}
+	m := deepCopy(c.baselines[section])
+	if err = setDefaults(m.Interface()); err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(merged, m.Interface()); err != nil {
+		return err
+	}
+	return walkFields(root, func(f *field) error {
+		mv := fieldByIndex(m.Elem(), f.index)
+		if !mv.IsValid() {
+			return nil
+		}
+		// a struct pointer is overridden as a whole if it is added or removed
+		changed := f.isLeaf() || (f.value.Kind() == reflect.Ptr && f.value.IsNil() != mv.IsNil())
+		if changed && !reflect.DeepEqual(f.value.Interface(), mv.Interface()) {
+			o.set(f, mv)
+		}
+		return nil
+	})
+}
+
+// merge merges the overlay into the base value by the strategy.
+func merge(base, overlay interface{}, strategy MergeStrategy) interface{} {
+	if strategy == MergeReplace {
+		return overlay
+	}
+	switch ov := overlay.(type) {
+	case map[interface{}]interface{}:
+		bv, ok := base.(map[interface{}]interface{})
+		if !ok {
+			return overlay
+		}
+		merged := make(map[interface{}]interface{}, len(bv)+len(ov))
+		for k, v := range bv {
+			merged[k] = v
+		}
+		for k, v := range ov {
+			merged[k] = merge(bv[k], v, strategy)
+		}
+		return merged
+	case []interface{}:
+		if bv, ok := base.([]interface{}); ok && strategy == MergeAppend {
+			return append(bv[:len(bv):len(bv)], ov...)
+		}
+	}
+	return overlay
+}
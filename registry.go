@@ -0,0 +1,161 @@
+package cfgo
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+)
+
+// Registry owns the Cfgo of every file and the default one.
+// The package-level functions use the default registry,
+// a new registry is isolated from it, such as in tests.
+type Registry struct {
+	cfgos       map[string]*Cfgo
+	defaultFile string
+	defaultSet  bool
+	defaultCfgo *Cfgo
+	lock        sync.Mutex
+}
+
+var defaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty registry, whose default file is 'config/config.yaml'
+// in the first of the search paths where it exists, see SetDefaultFile.
+func NewRegistry() *Registry {
+	return &Registry{
+		cfgos:       make(map[string]*Cfgo, 1),
+		defaultFile: "config/config.yaml",
+	}
+}
+
+// DefaultRegistry returns the registry used by the package-level functions.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Default returns the default config of the registry,
+// it is created again after it is closed.
+func (r *Registry) Default() *Cfgo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.defaultCfgo == nil {
+		c, err := r.get(r.defaultFilename(), nil)
+		if err != nil {
+			panic(err)
+		}
+		r.defaultCfgo = c
+	}
+	return r.defaultCfgo
+}
+
+// MustGet is similar to Get(), but panic if having error.
+func (r *Registry) MustGet(filename string, opts ...Option) *Cfgo {
+	c, err := r.Get(filename, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Get creates or gets a Cfgo.
+// The codec of the file is selected by its extension, see RegCodec.
+// The options are applied when the Cfgo is created, they are ignored if it exists.
+func (r *Registry) Get(filename string, opts ...Option) (*Cfgo, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.get(filename, opts)
+}
+
+func (r *Registry) get(filename string, opts []Option) (*Cfgo, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, fmt.Errorf("[cfgo] %w", err)
+	}
+	c := r.cfgos[abs]
+	if c != nil {
+		return c, nil
+	}
+	c = &Cfgo{
+		filename:        abs,
+		originalContent: []byte{},
+		content:         []byte{},
+		regConfigs:      make(map[string]Config),
+		baselines:       make(map[string]reflect.Value),
+		extraConfigs:    make(map[string]interface{}),
+		regSections:     make([]*Section, 0, 1),
+		extraSections:   make([]*Section, 0),
+		overrides:       make(map[string]overrides),
+		bound:           make(map[string][]byte),
+		envKeyFunc:      DefaultEnvKey,
+		flagValues:      make(map[string]map[string]string),
+		fileMode:        0666,
+		writeBack:       true,
+		lockTimeout:     DefaultLockTimeout,
+		registry:        r,
+		key:             abs,
+	}
+	if err = c.apply(opts); err != nil {
+		return nil, fmt.Errorf("[cfgo] %w", err)
+	}
+	err = c.reload(true)
+	if err != nil {
+		return nil, fmt.Errorf("[cfgo] %w", err)
+	}
+	if c.watch {
+		if err = c.Watch(c.watchErrFunc); err != nil {
+			return nil, err
+		}
+	}
+	r.cfgos[abs] = c
+	return c, nil
+}
+
+// ReloadAll reloads all configs of the registry.
+// The sections are reloaded only if they are changed, unless force is true.
+func (r *Registry) ReloadAll(force ...bool) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var errs MultiError
+	for _, c := range r.cfgos {
+		if err := c.Reload(force...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Close closes all configs of the registry.
+func (r *Registry) Close() error {
+	r.lock.Lock()
+	cfgos := make([]*Cfgo, 0, len(r.cfgos))
+	for _, c := range r.cfgos {
+		cfgos = append(cfgos, c)
+	}
+	r.lock.Unlock()
+	var errs MultiError
+	for _, c := range cfgos {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// remove removes the closed Cfgo.
+func (r *Registry) remove(c *Cfgo) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.cfgos[c.key] == c {
+		delete(r.cfgos, c.key)
+	}
+	if r.defaultCfgo == c {
+		r.defaultCfgo = nil
+	}
+}
@@ -0,0 +1,99 @@
+package cfgo
+
+import "reflect"
+
+// snapshot is the state of a registered section before it is bound,
+// which is restored if the binding fails.
+type snapshot struct {
+	c         *Cfgo
+	section   string
+	structPtr Config
+	value     reflect.Value
+	overrides overrides
+	bound     []byte
+}
+
+func (c *Cfgo) snapshot(section string) *snapshot {
+	structPtr := c.regConfigs[section]
+	return &snapshot{
+		c:         c,
+		section:   section,
+		structPtr: structPtr,
+		value:     deepCopy(reflect.ValueOf(structPtr)),
+		overrides: c.overrides[section],
+		bound:     c.bound[section],
+	}
+}
+
+// restore sets the state back to the struct, it can be used as the BindFunc.
+func (s *snapshot) restore() error {
+	assign(reflect.ValueOf(s.structPtr).Elem(), s.value.Elem())
+	s.c.overrides[s.section] = s.overrides
+	if s.bound == nil {
+		delete(s.c.bound, s.section)
+	} else {
+		s.c.bound[s.section] = s.bound
+	}
+	return nil
+}
+
+// rollback restores the state of a section that is bound successfully,
+// by the Reload callback so that the struct can react to the old values.
+func (s *snapshot) rollback() {
+	if s.c.callReload(s.section, s.structPtr, s.restore, s.bound) != nil {
+		s.restore()
+	}
+}
+
+// fileState is the state read from the files, which is restored if a synchronization fails,
+// so that the config still agrees with the structs and the files.
+type fileState struct {
+	originalContent []byte
+	content         []byte
+	extraConfigs    map[string]interface{}
+	regSections     Sections
+	extraSections   Sections
+	blocks          []*block
+	tailComments    [][]byte
+	overlays        map[string][]byte
+	overlaid        map[string]interface{}
+	dropIns         []*dropIn
+	origins         map[string]*dropIn
+	fileSections    map[string]interface{}
+	shadowed        Sections
+}
+
+func (c *Cfgo) fileState() *fileState {
+	return &fileState{
+		originalContent: c.originalContent,
+		content:         c.content,
+		extraConfigs:    c.extraConfigs,
+		regSections:     c.regSections,
+		extraSections:   c.extraSections,
+		blocks:          c.blocks,
+		tailComments:    c.tailComments,
+		overlays:        c.overlays,
+		overlaid:        c.overlaid,
+		dropIns:         c.dropIns,
+		origins:         c.origins,
+		fileSections:    c.fileSections,
+		shadowed:        c.shadowed,
+	}
+}
+
+// restore sets the state back to the config.
+func (s *fileState) restore(c *Cfgo) {
+	c.originalContent = s.originalContent
+	c.content = s.content
+	c.extraConfigs = s.extraConfigs
+	c.regSections = s.regSections
+	c.extraSections = s.extraSections
+	c.blocks = s.blocks
+	c.tailComments = s.tailComments
+	c.overlays = s.overlays
+	c.overlaid = s.overlaid
+	c.dropIns = s.dropIns
+	c.origins = s.origins
+	c.fileSections = s.fileSections
+	c.shadowed = s.shadowed
+}
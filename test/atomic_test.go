@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestBackup(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "backup.yaml")
+	const original = "server:\n  port: 80\n"
+	if err := ioutil.WriteFile(filename, []byte(original), 0640); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgo.MustGet(filename)
+	c.KeepBackup(true)
+	c.MustReg("server", new(F))
+
+	b, err := ioutil.ReadFile(filename + ".bak")
+	if err != nil || string(b) != "\n# ------------------------- non-automated configuration -------------------------\n\nserver:\n  port: 80\n" {
+		t.Fatalf("backup: %q, %v", b, err)
+	}
+	fi, err := os.Stat(filename)
+	if err != nil || fi.Mode().Perm() != 0640 {
+		t.Fatalf("mode: %v, %v", fi.Mode(), err)
+	}
+	// no temporary files are left
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Fatalf("files: %d, want 2", len(files))
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+type Codec struct {
+	Name  string `yaml:"name"`
+	Port  int
+	Hosts []string `yaml:",flow"`
+}
+
+func (c *Codec) Reload(bind cfgo.BindFunc) error {
+	return bind()
+}
+
+func TestCodec(t *testing.T) {
+	var cases = []struct {
+		file     string
+		original string
+		want     string
+	}{
+		{
+			file:     "codec.json",
+			original: `{"custom": {"a": 1}, "server": {"port": 8080}}`,
+			want: `{
+  "server": {
+    "hosts": [
+      "a",
+      "b"
+    ],
+    "name": "srv",
+    "port": 8080
+  },
+  "custom": {
+    "a": 1
+  }
+}
+`,
+		},
+		{
+			file:     "codec.toml",
+			original: "title = \"x\"\n[custom]\na = 1\nreleased = 1979-05-27T07:32:00Z\n[server]\nport = 8080\n",
+			want: `title = "x"
+
+[server]
+  hosts = ["a", "b"]
+  name = "srv"
+  port = 8080
+
+# ------------------------- non-automated configuration -------------------------
+
+[custom]
+  a = 1
+  released = 1979-05-27T07:32:00Z
+`,
+		},
+	}
+	for _, cc := range cases {
+		filename := filepath.Join(t.TempDir(), cc.file)
+		if err := ioutil.WriteFile(filename, []byte(cc.original), 0666); err != nil {
+			t.Fatal(err)
+		}
+		c := cfgo.MustGet(filename)
+		s := &Codec{Name: "srv", Hosts: []string{"a", "b"}}
+		c.MustReg("server", s)
+		if s.Port != 8080 {
+			t.Fatalf("%s: port: got %d, want 8080", cc.file, s.Port)
+		}
+		if got := string(c.Content()); got != cc.want {
+			t.Fatalf("%s: content:\n%s\nwant:\n%s", cc.file, got, cc.want)
+		}
+		var custom struct{ A int }
+		if err := c.BindSection("custom", &custom); err != nil || custom.A != 1 {
+			t.Fatalf("%s: BindSection: %v, %+v", cc.file, err, custom)
+		}
+	}
+}
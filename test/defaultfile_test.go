@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestDefaultFile(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	t.Setenv(cfgo.FileEnv, "")
+
+	// the working directory by default
+	r := cfgo.NewRegistry()
+	if abs, _ := filepath.Abs("config/config.yaml"); r.Default().Filename() != abs {
+		t.Fatalf("default: %s", r.Default().Filename())
+	}
+	r.Close()
+	os.RemoveAll("config")
+
+	// the first existing file of the search paths
+	exe, _ := os.Executable()
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	app := strings.TrimSuffix(filepath.Base(exe), filepath.Ext(exe))
+	filename := filepath.Join(xdg, app, "config.yaml")
+	os.MkdirAll(filepath.Dir(filename), 0777)
+	if err := ioutil.WriteFile(filename, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Default().Filename(); got != filename {
+		t.Fatalf("xdg: %s", got)
+	}
+	r.Close()
+
+	filename = filepath.Join(t.TempDir(), "env.yaml")
+	t.Setenv(cfgo.FileEnv, filename)
+	if got := r.Default().Filename(); got != filename {
+		t.Fatalf("env: %s", got)
+	}
+	r.Close()
+
+	filename = filepath.Join(t.TempDir(), "set.yaml")
+	r.SetDefaultFile(filename)
+	if got := r.Default().Filename(); got != filename {
+		t.Fatalf("set: %s", got)
+	}
+	r.Close()
+}
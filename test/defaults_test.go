@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+type D struct {
+	Host    string `default:"localhost"`
+	Port    int    `default:"8080"`
+	Tags    []string
+	Timeout int
+}
+
+func (d *D) Reload(bind cfgo.BindFunc) error {
+	return bind()
+}
+
+func (d *D) SetDefaults() {
+	if d.Tags == nil {
+		d.Tags = []string{"default"}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "defaults.yaml")
+	c := cfgo.MustGet(filename)
+	d := &D{Timeout: 30}
+	c.MustReg("server", d)
+	if d.Host != "localhost" || d.Port != 8080 || len(d.Tags) != 1 || d.Timeout != 30 {
+		t.Fatalf("config: %+v", d)
+	}
+
+	content := "server:\n  host: example.com\n  port: 80\n  tags: [a, b]\n  timeout: 5\n"
+	if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Host != "example.com" || d.Port != 80 || len(d.Tags) != 2 || d.Timeout != 5 {
+		t.Fatalf("config: %+v", d)
+	}
+
+	// the removed keys restore the defaults
+	if err := ioutil.WriteFile(filename, []byte("server:\n  host: example.com\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Host != "example.com" || d.Port != 8080 || len(d.Tags) != 1 || d.Timeout != 30 {
+		t.Fatalf("config: %+v", d)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+type DB struct {
+	Host  string
+	Port  int
+	Conns struct{ Size, Idle int }
+	diffs []cfgo.Diff
+}
+
+func (d *DB) Reload(bind cfgo.BindFunc) error {
+	return bind()
+}
+
+func (d *DB) ReloadWithDiff(bind cfgo.BindFunc, diff cfgo.Diff) error {
+	d.diffs = append(d.diffs, diff)
+	return bind()
+}
+
+func TestReloadWithDiff(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "diff.yaml")
+	c := cfgo.MustGet(filename)
+	d := &DB{Host: "localhost", Port: 80}
+	c.MustReg("db", d)
+	if len(d.diffs) != 1 || len(d.diffs[0]) != 4 || d.diffs[0][2].Path != "host" || d.diffs[0][2].Old != nil {
+		t.Fatalf("registered: %+v", d.diffs)
+	}
+
+	content := "db:\n  host: localhost\n  port: 8080\n  conns:\n    size: 10\n    idle: 0\n"
+	if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	diff := d.diffs[1]
+	if len(diff) != 2 || diff[0].Path != "conns.size" || diff[0].Old != 0 || diff[0].New != 10 ||
+		diff[1].Path != "port" || diff[1].Old != 80 || diff[1].New != 8080 {
+		t.Fatalf("reloaded: %+v", diff)
+	}
+	if !diff.Has("conns") || diff.Has("host") {
+		t.Fatalf("Has: %+v", diff)
+	}
+
+	if err := c.Update("db", func(v cfgo.Config) error {
+		v.(*DB).Host = "db.local"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if diff = d.diffs[2]; len(diff) != 1 || diff[0].Path != "host" || diff[0].New != "db.local" {
+		t.Fatalf("updated: %+v", diff)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestDropIns(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config.d")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	var files = map[string]string{
+		"00-main.yaml":  "db:\n  host: main\n  port: 1\n",
+		"10-db.yaml":    "# the database\ndb:\n  host: db.local\n  port: 3306\n\nmetrics: {on: true}\n",
+		"20-cache.toml": "[cache]\nhost = \"cache.local\"\nport = 6379\n",
+		"readme.txt":    "not a config file\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := cfgo.MustGet(dir, cfgo.WithDefaultFile(filepath.Join(dir, "00-main.yaml")))
+	db, cache, app := &F{}, &F{}, &F{Host: "localhost", Port: 80}
+	c.MustReg("db", db)
+	c.MustReg("cache", cache)
+	c.MustReg("app", app)
+	if db.Host != "db.local" || db.Port != 3306 || cache.Host != "cache.local" || cache.Port != 6379 {
+		t.Fatalf("db: %+v, cache: %+v", db, cache)
+	}
+	if _, ok := c.GetSection("metrics"); !ok {
+		t.Fatal("the extra section of a drop-in file is missing")
+	}
+
+	var want = map[string]string{
+		"00-main.yaml": "app:\n  host: localhost\n  port: 80\n  debug: false\n\n" +
+			"# ------------------------- non-automated configuration -------------------------\n\n" +
+			"db:\n  host: main\n  port: 1\n",
+		"10-db.yaml": "# the database\ndb:\n  host: db.local\n  port: 3306\n  debug: false\n\n" +
+			"# ------------------------- non-automated configuration -------------------------\n\n" +
+			"metrics: {on: true}\n",
+		"20-cache.toml": "[cache]\n  debug = false\n  host = \"cache.local\"\n  port = 6379\n",
+	}
+	for name, content := range want {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Fatalf("%s:\n%s\nwant:\n%s", name, b, content)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+type E struct {
+	Host     string
+	Port     int
+	Password string `env:"DB_PASSWORD"`
+}
+
+func (e *E) Reload(bind cfgo.BindFunc) error {
+	return bind()
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("APP_DB_PORT", "5432")
+	t.Setenv("DB_PASSWORD", "secret")
+	filename := filepath.Join(t.TempDir(), "env.yaml")
+	if err := ioutil.WriteFile(filename, []byte("db:\n  host: localhost\n  port: 3306\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgo.MustGet(filename)
+	c.SetEnvPrefix("APP")
+	e := new(E)
+	c.MustReg("db", e)
+	if e.Host != "localhost" || e.Port != 5432 || e.Password != "secret" {
+		t.Fatalf("config: %+v", e)
+	}
+	const want = "db:\n  host: localhost\n  port: 3306\n  password: \"\"\n"
+	if got := string(c.Content()); got != want {
+		t.Fatalf("content:\n%s\nwant:\n%s", got, want)
+	}
+
+	t.Setenv("APP_DB_PORT", "6543")
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if e.Port != 6543 {
+		t.Fatalf("port: got %d, want 6543", e.Port)
+	}
+	if got := string(c.Content()); got != want {
+		t.Fatalf("content:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestParseError(t *testing.T) {
+	var cases = []struct {
+		file    string
+		content string
+		line    int
+		column  int
+	}{
+		{"parse.yaml", "a:\n  b: 1\n c: 2\n", 3, 0},
+		{"scanner.yaml", "a: 1\nb: @x\n", 2, 0},
+		{"parse.json", "{\n  \"a\": x\n}\n", 2, 8},
+	}
+	for _, cc := range cases {
+		filename := filepath.Join(t.TempDir(), cc.file)
+		if err := ioutil.WriteFile(filename, []byte(cc.content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		_, err := cfgo.Get(filename)
+		var e *cfgo.ParseError
+		if !errors.As(err, &e) {
+			t.Fatalf("%s: error: %v", cc.file, err)
+		}
+		if e.File != filename || e.Line != cc.line || e.Column != cc.column {
+			t.Fatalf("%s: position: %s:%d:%d", cc.file, e.File, e.Line, e.Column)
+		}
+	}
+}
+
+func TestSectionError(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "errors.yaml")
+	c := cfgo.MustGet(filename)
+	c.MustReg("a", &V{Port: 80, Mode: "dev"})
+	c.MustReg("b", &V{Port: 81, Mode: "dev"})
+
+	content := "a:\n  port: 0\n  mode: dev\n\nb:\n  port: 81\n  mode: test\n"
+	if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	err := c.Reload()
+	var multi cfgo.MultiError
+	if !errors.As(err, &multi) || len(multi) != 2 {
+		t.Fatalf("error: %v", err)
+	}
+	for i, section := range []string{"a", "b"} {
+		var e *cfgo.SectionError
+		if !errors.As(multi[i], &e) || e.Section != section || e.File != filename {
+			t.Fatalf("error %d: %v", i, multi[i])
+		}
+	}
+
+	if err = c.Reg("a", &V{}); !errors.Is(err, cfgo.ErrDuplicateSection) {
+		t.Fatalf("duplicate: %v", err)
+	}
+	if err = c.Reg("c", nil); !errors.Is(err, cfgo.ErrNotStructPtr) {
+		t.Fatalf("not struct pointer: %v", err)
+	}
+	if err = c.BindSection("c", &V{}); !errors.Is(err, cfgo.ErrSectionNotFound) {
+		t.Fatalf("not found: %v", err)
+	}
+}
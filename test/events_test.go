@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andeya/cfgo"
+)
+
+func nextEvent(t *testing.T, events <-chan cfgo.Event) cfgo.Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	return cfgo.Event{}
+}
+
+func TestSubscribe(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "events.yaml")
+	c := cfgo.MustGet(filename)
+	events, cancel := c.Subscribe("http")
+	defer cancel()
+	all := make(chan cfgo.Event, 16)
+	c.OnChange(func(e cfgo.Event) {
+		all <- e
+	})
+
+	http := &R{Port: 80}
+	c.MustReg("http", http)
+	e := nextEvent(t, events)
+	if e.Kind != cfgo.EventRegistered || e.Old != nil || string(e.New) != "port: 80\n" {
+		t.Fatalf("%s: %q -> %q", e.Kind, e.Old, e.New)
+	}
+
+	content := "http:\n  port: 8080\n\nextra:\n  a: 1\n"
+	if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	e = nextEvent(t, events)
+	if e.Kind != cfgo.EventReloaded || string(e.Old) != "port: 80\n" || string(e.New) != "port: 8080\n" {
+		t.Fatalf("%s: %q -> %q", e.Kind, e.Old, e.New)
+	}
+
+	// unchanged
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte("http:\n  port: 8080\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"http registered", "extra extra section changed", "http reloaded", "extra removed"}
+	for _, w := range want {
+		if e := nextEvent(t, all); e.Section+" "+e.Kind.String() != w {
+			t.Fatalf("got %s %s, want %s", e.Section, e.Kind, w)
+		}
+	}
+	select {
+	case e := <-all:
+		t.Fatalf("unexpected event: %s %s", e.Section, e.Kind)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Fatal("the channel is not closed")
+	}
+}
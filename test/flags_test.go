@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestFlags(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "flags.yaml")
+	c := cfgo.MustGet(filename)
+	f := &F{Host: "localhost", Port: 80}
+	c.MustReg("server", f)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.BindFlags(fs)
+	if err := fs.Parse([]string{"--server.port=8080", "--server.debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Port != 8080 || !f.Debug || f.Host != "localhost" {
+		t.Fatalf("config: %+v", f)
+	}
+	const want = "server:\n  host: localhost\n  port: 80\n  debug: false\n"
+	if got := string(c.Content()); got != want {
+		t.Fatalf("content:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+type F struct {
+	Host  string
+	Port  int
+	Debug bool
+}
+
+func (f *F) Reload(bind cfgo.BindFunc) error {
+	return bind()
+}
+
+func TestKeepComments(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "format.yaml")
+	const original = `# the extra section
+custom: {a: 1,   b: 2}
+
+# the server
+server:
+  # listen port
+  port:   8080 # http
+  host: 'localhost'
+
+# end of file
+`
+	if err := ioutil.WriteFile(filename, []byte(original), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgo.MustGet(filename)
+	f := &F{Debug: true}
+	c.MustReg("server", f)
+
+	const want = `# the server
+server:
+  # listen port
+  port:   8080 # http
+  host: 'localhost'
+  debug: true
+
+# ------------------------- non-automated configuration -------------------------
+
+# the extra section
+custom: {a: 1,   b: 2}
+
+# end of file
+`
+	if got := string(c.Content()); got != want {
+		t.Fatalf("content:\n%s\nwant:\n%s", got, want)
+	}
+	if f.Port != 8080 || f.Host != "localhost" {
+		t.Fatalf("config: %+v", f)
+	}
+}
+
+type S struct {
+	Script string
+	Note   string
+}
+
+func (s *S) Reload(bind cfgo.BindFunc) error {
+	return bind()
+}
+
+func TestKeepBlockScalars(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "scalars.yaml")
+	const original = `job:
+  script: |
+    echo hi
+    # inside script
+  note: "a
+    # inside note"
+# the extra section
+extra:
+  run: >-
+    make
+    # inside run
+`
+	if err := ioutil.WriteFile(filename, []byte(original), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgo.MustGet(filename)
+	s := &S{}
+	c.MustReg("job", s)
+	if err := c.Reload(true); err != nil {
+		t.Fatal(err)
+	}
+	if s.Script != "echo hi\n# inside script\n" || s.Note != "a # inside note" {
+		t.Fatalf("config: %q", s)
+	}
+	const want = `job:
+  script: |
+    echo hi
+    # inside script
+  note: "a
+    # inside note"
+
+# ------------------------- non-automated configuration -------------------------
+
+# the extra section
+extra:
+  run: >-
+    make
+    # inside run
+`
+	if b, _ := ioutil.ReadFile(filename); string(b) != want {
+		t.Fatalf("content:\n%s\nwant:\n%s", b, want)
+	}
+}
+
+func TestKeepOrder(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "order.yaml")
+	const original = "c:\n  port: 3\n\na:\n  port: 1\n\nb:\n  port: 2\n"
+	if err := ioutil.WriteFile(filename, []byte(original), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgo.MustGet(filename)
+	for _, section := range []string{"b", "a", "c"} {
+		c.MustReg(section, new(F))
+	}
+	const want = "c:\n  port: 3\n  host: \"\"\n  debug: false\n\n" +
+		"a:\n  port: 1\n  host: \"\"\n  debug: false\n\n" +
+		"b:\n  port: 2\n  host: \"\"\n  debug: false\n"
+	if got := string(c.Content()); got != want {
+		t.Fatalf("content:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+type I struct {
+	Host string
+	Port int
+	URL  string
+	Home string
+}
+
+func (i *I) Reload(bind cfgo.BindFunc) error {
+	return bind()
+}
+
+func TestInterpolate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "interpolate.yaml")
+	original := "server:\n" +
+		"  host: ${HOST:-localhost}\n" +
+		"  port: ${PORT}\n" +
+		"  url: http://${common.domain}:${PORT}/$${path}\n" +
+		"  home: /srv\n" +
+		"\n" +
+		"# ------------------------- non-automated configuration -------------------------\n" +
+		"\n" +
+		"common:\n" +
+		"  domain: example.com\n"
+	if err := ioutil.WriteFile(filename, []byte(original), 0666); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PORT", "8080")
+	c := cfgo.MustGet(filename)
+	i := &I{}
+	c.MustReg("server", i)
+	if i.Host != "localhost" || i.Port != 8080 || i.URL != "http://example.com:8080/${path}" {
+		t.Fatalf("config: %+v", i)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != original {
+		t.Fatalf("placeholders are not kept:\n%s", b)
+	}
+
+	err = c.Update("server", func(cfg cfgo.Config) error {
+		cfg.(*I).Port = 9090
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = ioutil.ReadFile(filename)
+	if !strings.Contains(string(b), "  host: ${HOST:-localhost}\n  port: 9090\n") {
+		t.Fatalf("updated:\n%s", b)
+	}
+
+	os.Unsetenv("PORT")
+	if err = ioutil.WriteFile(filename, []byte(original), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Reload(); err == nil || !strings.Contains(err.Error(), "section server: interpolation") {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestInterpolateShrink(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "shrink.yaml")
+	if err := ioutil.WriteFile(filename, []byte("server:\n  hosts:\n  - ${H1}\n  - ${H2}\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("H1", "a")
+	t.Setenv("H2", "b")
+	c := cfgo.MustGet(filename)
+	p := &P{}
+	c.MustReg("server", p)
+	if len(p.Hosts) != 2 || p.Hosts[0] != "a" || p.Hosts[1] != "b" {
+		t.Fatalf("hosts: %v", p.Hosts)
+	}
+	err := c.Update("server", func(cfg cfgo.Config) error {
+		cfg.(*P).Hosts = []string{"z"}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filename); !strings.Contains(string(b), "  hosts:\n  - z\n") || strings.Contains(string(b), "${") {
+		t.Fatalf("updated:\n%s", b)
+	}
+}
+
+func TestInterpolateDropIns(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config.d")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	var files = map[string]string{
+		"default.yaml":   "server:\n  url: http://${common.domain}/\n",
+		"10-common.yaml": "common:\n  domain: example.com\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := cfgo.MustGet(dir)
+	i := &I{}
+	c.MustReg("server", i)
+	if i.URL != "http://example.com/" {
+		t.Fatalf("url: %q", i.URL)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestShareLock(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Symlink(dir, dir+".link"); err != nil {
+		t.Skip(err)
+	}
+	defer os.Remove(dir + ".link")
+
+	// two instances of the same file act as two applications
+	c1 := cfgo.MustGet(filepath.Join(dir, "share.yaml"), true)
+	c2 := cfgo.MustGet(filepath.Join(dir+".link", "share.yaml"), true)
+	if c1 == c2 {
+		t.Fatal("want different instances")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			c1.MustReg(fmt.Sprintf("a%d", i), new(M))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			c2.MustReg(fmt.Sprintf("b%d", i), new(M))
+		}(i)
+	}
+	wg.Wait()
+	if err := c1.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		for _, section := range []string{fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i)} {
+			if _, ok := c1.GetSection(section); !ok {
+				t.Fatalf("lost section %s:\n%s", section, c1.Content())
+			}
+		}
+	}
+}
+
+// Merged changes the file on its first validation, as another application not locking it.
+type Merged struct {
+	Port     int
+	filename string
+	loads    int
+}
+
+func (m *Merged) Reload(bind cfgo.BindFunc) error {
+	m.loads++
+	return bind()
+}
+
+func (m *Merged) Validate() error {
+	if b, _ := ioutil.ReadFile(m.filename); !strings.Contains(string(b), "other") {
+		return ioutil.WriteFile(m.filename, []byte("merged:\n  port: 8080\nother: 1\n"), 0666)
+	}
+	return nil
+}
+
+func TestMergeOnce(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "merge.yaml")
+	c := cfgo.MustGet(filename, true)
+	m := &Merged{Port: 80, filename: filename}
+	c.MustReg("merged", m)
+	if m.loads != 1 || m.Port != 8080 {
+		t.Fatalf("loads: %d, port: %d", m.loads, m.Port)
+	}
+	if _, ok := c.GetSection("other"); !ok {
+		t.Fatalf("lost the change:\n%s", c.Content())
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestOptions(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "options.conf")
+	original := "custom:\n  a: 1\n"
+	if err := ioutil.WriteFile(filename, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cfgo.Get(filename,
+		cfgo.WithCodec(cfgo.YAML),
+		cfgo.WithWriteBack(false),
+		cfgo.WithDividingLine("# --- local ---"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &F{Host: "localhost", Port: 80}
+	c.MustReg("server", f)
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != original {
+		t.Fatalf("file is written back:\n%s", b)
+	}
+	if !strings.Contains(string(c.Content()), "\n# --- local ---\n\ncustom:\n") {
+		t.Fatalf("content:\n%s", c.Content())
+	}
+	if err = c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ = ioutil.ReadFile(filename); string(b) != string(c.Content()) {
+		t.Fatalf("file is not saved:\n%s", b)
+	}
+	if err = c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ = ioutil.ReadFile(filename); strings.Count(string(b), "# --- local ---") != 1 {
+		t.Fatalf("dividing line is repeated:\n%s", b)
+	}
+
+	filename = filepath.Join(dir, "mode.yaml")
+	cfgo.MustGet(filename, cfgo.WithFileMode(0600))
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("mode: %s", fi.Mode())
+	}
+
+	if _, err = cfgo.Get(filepath.Join(dir, "invalid.yaml"), 0600); err == nil {
+		t.Fatal("invalid option is accepted")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+type P struct {
+	Host  string
+	Port  int
+	Hosts []string `yaml:",flow"`
+	DB    struct {
+		Name string
+		User string
+	}
+}
+
+func (p *P) Reload(bind cfgo.BindFunc) error {
+	return bind()
+}
+
+func TestProfile(t *testing.T) {
+	var cases = []struct {
+		strategy cfgo.MergeStrategy
+		want     P
+	}{
+		{cfgo.MergeDeep, P{Host: "prod.example.com", Port: 80, Hosts: []string{"c"}}},
+		{cfgo.MergeAppend, P{Host: "prod.example.com", Port: 80, Hosts: []string{"a", "b", "c"}}},
+		{cfgo.MergeReplace, P{Host: "prod.example.com", Port: 0, Hosts: []string{"c"}}},
+	}
+	for _, cc := range cases {
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "config.yaml")
+		base := "server:\n  host: localhost\n  port: 80\n  hosts: [a, b]\n  db:\n    name: test\n    user: root\n"
+		overlay := "server:\n  host: prod.example.com\n  hosts: [c]\n  db:\n    name: prod\n"
+		if err := ioutil.WriteFile(filename, []byte(base), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "config.production.yaml"), []byte(overlay), 0666); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(cfgo.ProfileEnv, "production")
+		c := cfgo.MustGet(filename, cfgo.WithMergeStrategy(cc.strategy))
+		p := &P{}
+		c.MustReg("server", p)
+
+		cc.want.DB.Name = "prod"
+		if cc.strategy != cfgo.MergeReplace {
+			cc.want.DB.User = "root"
+		}
+		if !reflect.DeepEqual(*p, cc.want) {
+			t.Fatalf("strategy %d: got %+v, want %+v", cc.strategy, *p, cc.want)
+		}
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != base {
+			t.Fatalf("strategy %d: base file is changed:\n%s", cc.strategy, b)
+		}
+	}
+}
+
+func TestWriteBackFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "base.yaml")
+	base := "server:\n  host: localhost\n"
+	if err := ioutil.WriteFile(filename, []byte(base), 0666); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "rendered", "config.yaml")
+	c := cfgo.MustGet(filename, cfgo.WithWriteBackFile(target), cfgo.WithProfile(""))
+	c.MustReg("server", &F{Port: 80})
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != base {
+		t.Fatalf("base file is changed:\n%s", b)
+	}
+	if b, err = ioutil.ReadFile(target); err != nil || !strings.Contains(string(b), "port: 80") {
+		t.Fatalf("rendered file: %v\n%s", err, b)
+	}
+}
+
+func TestProfileExtras(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.yaml")
+	base := "custom:\n  host: localhost\n  port: 80\n"
+	overlay := "custom:\n  host: prod.example.com\nother:\n  port: 81\n"
+	if err := ioutil.WriteFile(filename, []byte(base), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.production.yaml"), []byte(overlay), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgo.MustGet(filename, cfgo.WithProfile("production"))
+	c.MustReg("server", &F{Port: 8080})
+
+	var custom, other P
+	if err := c.BindSection("custom", &custom); err != nil || custom.Host != "prod.example.com" || custom.Port != 80 {
+		t.Fatalf("custom: %v, %+v", err, custom)
+	}
+	if err := c.BindSection("other", &other); err != nil || other.Port != 81 {
+		t.Fatalf("other: %v, %+v", err, other)
+	}
+	if _, ok := c.GetSection("other"); !ok {
+		t.Fatal("GetSection: the overlay section is not found")
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); !strings.Contains(s, "  host: localhost\n") || strings.Contains(s, "prod") || strings.Contains(s, "other") {
+		t.Fatalf("the overlay is written:\n%s", b)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "readonly.yaml")
+	original := "server:\n  host: example.com\n  port: 8080\n  unknown: 1\n"
+	if err := ioutil.WriteFile(filename, []byte(original), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgo.MustGet(filename, cfgo.WithReadOnly(true))
+	f := &F{Host: "localhost", Port: 80}
+	c.MustReg("server", f)
+	if f.Host != "example.com" || f.Port != 8080 {
+		t.Fatalf("config: %+v", f)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != original {
+		t.Fatalf("file is written:\n%s", b)
+	}
+	if strings.Contains(string(c.Content()), "unknown") || !strings.Contains(string(c.Content()), "debug: false") {
+		t.Fatalf("content:\n%s", c.Content())
+	}
+	if err = c.Save(); !errors.Is(err, cfgo.ErrReadOnly) {
+		t.Fatalf("save: %v", err)
+	}
+
+	// the file and its directory are not created
+	t.Setenv(cfgo.ReadOnlyEnv, "true")
+	filename = filepath.Join(dir, "missing", "readonly.yaml")
+	c = cfgo.MustGet(filename)
+	c.MustReg("server", &F{Host: "localhost", Port: 80})
+	if _, err = os.Stat(filepath.Dir(filename)); !os.IsNotExist(err) {
+		t.Fatalf("directory is created: %v", err)
+	}
+	if !strings.Contains(string(c.Content()), "host: localhost") {
+		t.Fatalf("content:\n%s", c.Content())
+	}
+}
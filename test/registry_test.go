@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestRegistry(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	r1, r2 := cfgo.NewRegistry(), cfgo.NewRegistry()
+	c1, c2 := r1.MustGet("config/registry.yaml"), r2.MustGet("config/registry.yaml")
+	if c1 == c2 || c1 == cfgo.MustGet("config/registry.yaml") {
+		t.Fatal("the registries are not isolated")
+	}
+	if r1.MustGet("config/registry.yaml") != c1 {
+		t.Fatal("the Cfgo is not kept")
+	}
+
+	d := r1.Default()
+	if d.Filename() != filepath.Join(filepath.Dir(c1.Filename()), "config.yaml") {
+		t.Fatalf("default: %s", d.Filename())
+	}
+	if err := r1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r1.Default() == d || r1.MustGet("config/registry.yaml") == c1 {
+		t.Fatal("the closed configs are kept")
+	}
+	r1.Close()
+	r2.Close()
+}
+
+func TestRegistryPattern(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config.d")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	r := cfgo.NewRegistry()
+	c := r.MustGet(dir)
+	if r.MustGet(dir) != c {
+		t.Fatal("the Cfgo of the directory is not kept")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r.MustGet(dir) == c {
+		t.Fatal("the closed Cfgo of the directory is kept")
+	}
+	r.Close()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+type R struct {
+	Port  int
+	loads int
+}
+
+func (r *R) Reload(bind cfgo.BindFunc) error {
+	r.loads++
+	return bind()
+}
+
+func TestReloadChanged(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "reload.yaml")
+	c := cfgo.MustGet(filename)
+	db, http := &R{Port: 3306}, &R{Port: 80}
+	c.MustReg("db", db)
+	c.MustReg("http", http)
+
+	content := "db:\n  port: 3306\n\nhttp:\n  port: 8080\n"
+	if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if db.loads != 1 || http.loads != 2 || http.Port != 8080 {
+		t.Fatalf("db: %+v, http: %+v", db, http)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if db.loads != 1 || http.loads != 2 {
+		t.Fatalf("unchanged sections are reloaded: db: %+v, http: %+v", db, http)
+	}
+	if err := c.Reload(true); err != nil {
+		t.Fatal(err)
+	}
+	if db.loads != 2 || http.loads != 3 {
+		t.Fatalf("force: db: %+v, http: %+v", db, http)
+	}
+}
+
+func TestReloadTransaction(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "transaction.yaml")
+	if err := ioutil.WriteFile(filename, []byte("extra:\n  k: v\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgo.MustGet(filename)
+	a, b := &V{Port: 80, Mode: "dev"}, &V{Port: 81, Mode: "dev"}
+	c.MustReg("a", a)
+	c.MustReg("b", b)
+	before := string(c.Content())
+
+	content := "a:\n  port: 8000\n  mode: prod\n\nb:\n  port: 70000\n  mode: dev\n\nextra:\n  k: w\n"
+	if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err == nil {
+		t.Fatal("invalid section b is reloaded")
+	}
+	if a.loads != 1 || a.Port != 80 || a.Mode != "dev" {
+		t.Fatalf("section a is changed: %+v", a)
+	}
+	if b.loads != 1 || b.Port != 81 {
+		t.Fatalf("section b is changed: %+v", b)
+	}
+	got, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Fatalf("file is changed:\n%s", got)
+	}
+	if string(c.Content()) != before {
+		t.Fatalf("content is changed:\n%s", c.Content())
+	}
+	var extra struct{ K string }
+	if err = c.BindSection("extra", &extra); err != nil || extra.K != "v" {
+		t.Fatalf("extra section is changed: %v, %+v", err, extra)
+	}
+	if v, ok := c.GetSection("extra"); !ok || fmt.Sprint(v) != "map[k:v]" {
+		t.Fatalf("extra section is changed: %v", v)
+	}
+}
+
+type Failing struct {
+	Port int
+	fail bool
+}
+
+func (f *Failing) Reload(bind cfgo.BindFunc) error {
+	if err := bind(); err != nil {
+		return err
+	}
+	if f.fail {
+		return errors.New("cannot listen")
+	}
+	return nil
+}
+
+func TestReloadRollback(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rollback.yaml")
+	c := cfgo.MustGet(filename)
+	a, b := &R{Port: 80}, &Failing{Port: 81}
+	c.MustReg("a", a)
+	c.MustReg("b", b)
+
+	b.fail = true
+	content := "a:\n  port: 8000\n\nb:\n  port: 8001\n"
+	if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err == nil || !strings.Contains(err.Error(), "cannot listen") {
+		t.Fatalf("error: %v", err)
+	}
+	if b.Port != 81 {
+		t.Fatalf("section b is not restored: %+v", b)
+	}
+	// a is bound with the new values and then rolled back by Reload
+	if a.Port != 80 || a.loads != 3 {
+		t.Fatalf("section a is not rolled back: %+v", a)
+	}
+
+	b.fail = false
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Port != 8000 || b.Port != 8001 {
+		t.Fatalf("a: %+v, b: %+v", a, b)
+	}
+}
+
+type Pool struct {
+	Size  int
+	Conns int `yaml:"-"`
+}
+
+func (p *Pool) Reload(bind cfgo.BindFunc) error {
+	return bind()
+}
+
+func TestReloadIgnored(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "ignored.yaml")
+	c := cfgo.MustGet(filename)
+	p := &Pool{Size: 10}
+	c.MustReg("pool", p)
+
+	p.Conns = 7
+	if err := c.Reload(true); err != nil {
+		t.Fatal(err)
+	}
+	if p.Size != 10 || p.Conns != 7 {
+		t.Fatalf("the ignored field is reset: %+v", p)
+	}
+}
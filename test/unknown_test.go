@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestUnknownKeys(t *testing.T) {
+	const original = "server:\n  host: localhost\n  hots: typo\n  port: 80\n  tls:\n    cert: a.pem\n"
+
+	filename := filepath.Join(t.TempDir(), "keep.yaml")
+	if err := ioutil.WriteFile(filename, []byte(original), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgo.MustGet(filename)
+	c.SetUnknownKeyMode(cfgo.KeepUnknownKeys)
+	f := new(F)
+	c.MustReg("server", f)
+	const want = "server:\n  host: localhost\n  hots: typo\n  port: 80\n  tls:\n    cert: a.pem\n  debug: false\n"
+	if got := string(c.Content()); got != want {
+		t.Fatalf("content:\n%s\nwant:\n%s", got, want)
+	}
+
+	filename = filepath.Join(t.TempDir(), "strict.yaml")
+	if err := ioutil.WriteFile(filename, []byte(original), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c = cfgo.MustGet(filename)
+	c.SetUnknownKeyMode(cfgo.StrictUnknownKeys)
+	err := c.Reg("server", new(F))
+	if err == nil || !strings.Contains(err.Error(), "section server:") ||
+		!strings.Contains(err.Error(), "field hots not found") || !strings.Contains(err.Error(), "field tls not found") {
+		t.Fatalf("error: %v", err)
+	}
+}
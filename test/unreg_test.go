@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestUnreg(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "unreg.yaml")
+	c := cfgo.MustGet(filename)
+	c.MustReg("a", &R{Port: 80})
+	c.MustReg("b", &R{Port: 81})
+	events, cancel := c.Subscribe("a")
+	defer cancel()
+
+	if err := c.Unreg("a"); err != nil {
+		t.Fatal(err)
+	}
+	if c.IsReg("a") {
+		t.Fatal("a is still registered")
+	}
+	if e := nextEvent(t, events); e.Kind != cfgo.EventRemoved || string(e.New) != "port: 80\n" {
+		t.Fatalf("%s: %q -> %q", e.Kind, e.Old, e.New)
+	}
+	want := "b:\n  port: 81\n\n# ------------------------- non-automated configuration -------------------------\n\na:\n  port: 80\n"
+	if b, _ := ioutil.ReadFile(filename); string(b) != want {
+		t.Fatalf("kept:\n%s", b)
+	}
+	if err := c.Unreg("a"); !errors.Is(err, cfgo.ErrSectionNotFound) {
+		t.Fatalf("unregistered twice: %v", err)
+	}
+
+	if err := c.Unreg("b", true); err != nil {
+		t.Fatal(err)
+	}
+	want = "\n# ------------------------- non-automated configuration -------------------------\n\na:\n  port: 80\n"
+	if b, _ := ioutil.ReadFile(filename); string(b) != want {
+		t.Fatalf("dropped:\n%s", b)
+	}
+
+	// registered again with the kept values
+	r := &R{}
+	c.MustReg("a", r)
+	if r.Port != 80 {
+		t.Fatalf("port: %d", r.Port)
+	}
+}
+
+func TestClose(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "close.yaml")
+	c := cfgo.MustGet(filename)
+	if err := c.Watch(nil); err != nil {
+		t.Fatal(err)
+	}
+	events, _ := c.Subscribe("")
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("the subscription is not closed")
+	}
+	if cfgo.MustGet(filename) == c {
+		t.Fatal("the closed Cfgo is returned")
+	}
+}
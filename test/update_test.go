@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+func TestUpdate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "update.yaml")
+	c := cfgo.MustGet(filename)
+	v := &V{Port: 80, Mode: "dev"}
+	c.MustReg("server", v)
+
+	err := c.Update("server", func(cfg cfgo.Config) error {
+		cfg.(*V).Port = 8080
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Port != 8080 || v.loads != 2 {
+		t.Fatalf("config: %+v", v)
+	}
+	const want = "server:\n  port: 8080\n  mode: dev\n"
+	if got := string(c.Content()); got != want {
+		t.Fatalf("content:\n%s\nwant:\n%s", got, want)
+	}
+
+	// the invalid or failed updates are rolled back
+	err = c.Update("server", func(cfg cfgo.Config) error {
+		cfg.(*V).Port = 70000
+		return nil
+	})
+	if err == nil || v.Port != 8080 {
+		t.Fatalf("error: %v, config: %+v", err, v)
+	}
+	err = c.Update("server", func(cfg cfgo.Config) error {
+		cfg.(*V).Mode = "prod"
+		return errors.New("failed")
+	})
+	if err == nil || v.Mode != "dev" {
+		t.Fatalf("error: %v, config: %+v", err, v)
+	}
+	if got := string(c.Content()); got != want {
+		t.Fatalf("content:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUpdateSection(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "section.yaml")
+	c := cfgo.MustGet(filename)
+	a, b := &F{Port: 1}, &F{Port: 1}
+	c.MustReg("a", a)
+	c.MustReg("b", b)
+
+	content := strings.Replace(string(c.Content()), "b:\n  host: \"\"\n  port: 1\n", "b:\n  host: \"\"\n  port: 42\n", 1)
+	if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	err := c.Update("a", func(cfg cfgo.Config) error {
+		cfg.(*F).Port = 2
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ioutil.ReadFile(filename)
+	if !strings.Contains(string(got), "a:\n  host: \"\"\n  port: 2\n") || !strings.Contains(string(got), "  port: 42\n") {
+		t.Fatalf("the edit of the other section is lost:\n%s", got)
+	}
+}
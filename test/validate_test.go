@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andeya/cfgo"
+)
+
+type V struct {
+	Port  int    `validate:"required,min=1,max=65535"`
+	Mode  string `validate:"oneof=dev prod"`
+	loads int
+}
+
+func (v *V) Reload(bind cfgo.BindFunc) error {
+	v.loads++
+	return bind()
+}
+
+func (v *V) Validate() error {
+	if v.Mode == "prod" && v.Port == 8080 {
+		return errors.New("port 8080 is not allowed in prod")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "validate.yaml")
+	c := cfgo.MustGet(filename)
+	v := &V{Port: 80, Mode: "dev"}
+	c.MustReg("server", v)
+
+	var cases = []struct {
+		content string
+		err     string
+	}{
+		{"server:\n  port: 70000\n  mode: dev\n", `section server: field port: validate "max=65535"`},
+		{"server:\n  port: 80\n  mode: test\n", `section server: field mode: validate "oneof=dev prod"`},
+		{"server:\n  port: 8080\n  mode: prod\n", "section server: port 8080 is not allowed in prod"},
+	}
+	for _, cc := range cases {
+		if err := ioutil.WriteFile(filename, []byte(cc.content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		err := c.Reload()
+		if err == nil || !strings.Contains(err.Error(), cc.err) {
+			t.Fatalf("error: got %v, want %s", err, cc.err)
+		}
+		if v.loads != 1 || v.Port != 80 || v.Mode != "dev" {
+			t.Fatalf("config: %+v", v)
+		}
+	}
+}
+
+// Dur keeps its state in an unexported field.
+type Dur struct{ d string }
+
+func (d *Dur) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return unmarshal(&d.d)
+}
+
+func (d Dur) MarshalYAML() (interface{}, error) {
+	return d.d, nil
+}
+
+type Timeout struct {
+	Timeout Dur
+	Retry   struct{ Max int }
+}
+
+func (t *Timeout) Reload(bind cfgo.BindFunc) error {
+	return bind()
+}
+
+func TestAssignUnmarshaler(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "unmarshaler.yaml")
+	const content = "client:\n  timeout: 5s\n  retry:\n    max: 3\n"
+	if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := cfgo.MustGet(filename)
+	v := &Timeout{}
+	c.MustReg("client", v)
+	if v.Timeout.d != "5s" || v.Retry.Max != 3 {
+		t.Fatalf("config: %+v", v)
+	}
+	if b, _ := ioutil.ReadFile(filename); string(b) != content {
+		t.Fatalf("content:\n%s", b)
+	}
+}
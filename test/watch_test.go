@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andeya/cfgo"
+)
+
+type W struct {
+	Port    int
+	reloads int32
+}
+
+func (w *W) Reload(bind cfgo.BindFunc) error {
+	err := bind()
+	atomic.AddInt32(&w.reloads, 1)
+	return err
+}
+
+func TestWatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "watch.yaml")
+	c := cfgo.MustGet(filename)
+	w := &W{Port: 80}
+	c.MustReg("server", w)
+	if err := c.Watch(func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Unwatch()
+
+	if err := ioutil.WriteFile(filename, []byte("server:\n  port: 8080\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&w.reloads) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.Port != 8080 {
+		t.Fatalf("port: got %d, want 8080", w.Port)
+	}
+
+	// the rewrite done by the reload itself must not trigger another reload
+	time.Sleep(3 * cfgo.WatchDebounce)
+	if n := atomic.LoadInt32(&w.reloads); n != 2 {
+		t.Fatalf("reloads: got %d, want 2", n)
+	}
+}
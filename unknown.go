@@ -0,0 +1,141 @@
+package cfgo
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+// UnknownKeyMode is the handling of the keys in a registered section,
+// which have no fields in the struct.
+type UnknownKeyMode int
+
+const (
+	// DropUnknownKeys removes the unknown keys from the file, by default.
+	DropUnknownKeys UnknownKeyMode = iota
+	// KeepUnknownKeys keeps the unknown keys in the file.
+	KeepUnknownKeys
+	// StrictUnknownKeys reports the unknown keys as errors.
+	StrictUnknownKeys
+)
+
+// SetUnknownKeyMode sets the handling of the unknown keys in the default config sections.
+func SetUnknownKeyMode(mode UnknownKeyMode) {
+	Default().SetUnknownKeyMode(mode)
+}
+
+// SetUnknownKeyMode sets the handling of the keys in the registered sections,
+// which have no fields in the structs, it takes effect on the next reload.
+func (c *Cfgo) SetUnknownKeyMode(mode UnknownKeyMode) {
+	c.lc.Lock()
+	c.unknownKeyMode = mode
+	c.lc.Unlock()
+}
+
+// checkUnknownKeys reports the unknown keys of the section in strict mode.
+func (c *Cfgo) checkUnknownKeys(t reflect.Type, b []byte) error {
+	if c.unknownKeyMode != StrictUnknownKeys || b == nil {
+		return nil
+	}
+	return yaml.UnmarshalStrict(b, reflect.New(t).Interface())
+}
+
+// withUnknownKeys returns the value to be written with the unknown keys in the file,
+// if they are kept. The unknown keys are the ones lost by decoding the file into the struct.
+func (c *Cfgo) withUnknownKeys(v interface{}, b []byte) (interface{}, error) {
+	if c.unknownKeyMode != KeepUnknownKeys || b == nil {
+		return v, nil
+	}
+	var file, known interface{}
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return nil, err
+	}
+	decoded := reflect.New(reflect.TypeOf(v).Elem())
+	if err := yaml.Unmarshal(b, decoded.Interface()); err != nil {
+		return nil, err
+	}
+	out, err := yaml.Marshal(decoded.Interface())
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(out, &known); err != nil {
+		return nil, err
+	}
+	paths := missingKeys(file, known, nil)
+	if len(paths) == 0 {
+		return v, nil
+	}
+	if out, err = yaml.Marshal(v); err != nil {
+		return nil, err
+	}
+	var ms yaml.MapSlice
+	if err = yaml.Unmarshal(out, &ms); err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		value, _ := lookupPath(file, p)
+		ms = insertPath(ms, p, value)
+	}
+	return ms, nil
+}
+
+// missingKeys returns the paths of the mapping keys in v, which are missing from known.
+func missingKeys(v, known interface{}, path []interface{}) [][]interface{} {
+	m, _ := v.(map[interface{}]interface{})
+	km, ok := known.(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+	var paths [][]interface{}
+	for k, vv := range m {
+		p := append(path[:len(path):len(path)], k)
+		if kv, ok := km[k]; ok {
+			paths = append(paths, missingKeys(vv, kv, p)...)
+		} else {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// lookupPath returns the value at the path, false if the path does not exist,
+// such as an index out of the sequence or a key of a scalar.
+func lookupPath(v interface{}, path []interface{}) (interface{}, bool) {
+	for _, k := range path {
+		switch x := v.(type) {
+		case map[interface{}]interface{}:
+			var ok bool
+			if v, ok = x[k]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, ok := k.(int)
+			if !ok || i < 0 || i >= len(x) {
+				return nil, false
+			}
+			v = x[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// insertPath adds the value to the mapping at the path,
+// the existing keys and the paths through sequences or missing mappings are skipped.
+func insertPath(ms yaml.MapSlice, path []interface{}, value interface{}) yaml.MapSlice {
+	for i, item := range ms {
+		if fmt.Sprint(item.Key) != fmt.Sprint(path[0]) {
+			continue
+		}
+		if sub, ok := item.Value.(yaml.MapSlice); ok && len(path) > 1 {
+			ms[i].Value = insertPath(sub, path[1:], value)
+		}
+		return ms
+	}
+	if len(path) == 1 {
+		return append(ms, yaml.MapItem{Key: path[0], Value: value})
+	}
+	return ms
+}
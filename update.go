@@ -0,0 +1,99 @@
+package cfgo
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Update changes the default config section and saves it.
+func Update(section string, fn func(Config) error) error {
+	return Default().Update(section, fn)
+}
+
+// Save writes the registered sections of the default config to its file.
+func Save() error {
+	return Default().Save()
+}
+
+// Update changes the registered section and saves it to the file.
+// fn mutates a copy of the registered struct, which is validated and then
+// bound by the Reload callback. The struct is rolled back on failure.
+func (c *Cfgo) Update(section string, fn func(Config) error) error {
+	c.lc.Lock()
+	defer c.lc.Unlock()
+	if c.readOnly {
+		return fmt.Errorf("[cfgo] %w: %s", ErrReadOnly, c.filename)
+	}
+	structPtr, ok := c.regConfigs[section]
+	if !ok {
+		return fmt.Errorf("[cfgo] %w: %s", ErrSectionNotFound, section)
+	}
+	live := reflect.ValueOf(structPtr)
+	backup := c.snapshot(section)
+
+	v := deepCopy(live)
+	if err := fn(v.Interface().(Config)); err != nil {
+		return fmt.Errorf("[cfgo] %w", c.sectionError(section, err))
+	}
+	if err := validate(v.Interface()); err != nil {
+		return fmt.Errorf("[cfgo] %w", c.sectionError(section, err))
+	}
+	// the changed fields are not overridden any more, so that they are saved
+	var o overrides
+	for _, x := range backup.overrides {
+		changed, old := fieldByIndex(v.Elem(), x.index), fieldByIndex(live.Elem(), x.index)
+		if changed.IsValid() && old.IsValid() && reflect.DeepEqual(changed.Interface(), old.Interface()) {
+			o = append(o, x)
+		}
+	}
+
+	single, err := yaml.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Errorf("[cfgo] %w", c.sectionError(section, err))
+	}
+
+	err = c.callReload(section, structPtr, c.commitFunc(section, structPtr, v, o, single), single)
+	if err != nil {
+		backup.restore()
+		return fmt.Errorf("[cfgo] %w", c.sectionError(section, err))
+	}
+	// only the updated section is written from the struct
+	c.updating = section
+	defer func() { c.updating = "" }()
+	if err = c.save(); err != nil {
+		backup.rollback()
+		return err
+	}
+	return nil
+}
+
+// Save writes the current values of the registered structs to the file,
+// the non-automated sections are kept.
+func (c *Cfgo) Save() error {
+	c.lc.Lock()
+	defer c.lc.Unlock()
+	return c.save()
+}
+
+func (c *Cfgo) save() error {
+	if c.readOnly {
+		return fmt.Errorf("[cfgo] %w: %s", ErrReadOnly, c.filename)
+	}
+	return c.sync(func(string, Config, []byte) (func() error, error) {
+		return nil, nil
+	}, true)
+}
+
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			if v = indirect(v); !v.IsValid() {
+				return v
+			}
+		}
+		v = v.Field(x)
+	}
+	return v
+}
@@ -0,0 +1,122 @@
+package cfgo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator is implemented by the config structs that check themselves.
+// It is called on the new values before Reload, after the `validate` tags are checked.
+//
+// The `validate` tag is a comma-separated list of the rules:
+//
+//	required     The value must not be the zero value.
+//
+//	min=N        The number must not be less than N, or the length of
+//	             the string, slice or map must not be less than N.
+//
+//	max=N        The number must not be greater than N, or the length of
+//	             the string, slice or map must not be greater than N.
+//
+//	oneof=A B    The value must be one of the space-separated values.
+type Validator interface {
+	Validate() error
+}
+
+// validate checks the `validate` tags of the fields,
+// and then the Validator implementations.
+func validate(structPtr interface{}) error {
+	err := walkFields(reflect.ValueOf(structPtr).Elem(), func(f *field) error {
+		path := strings.Join(f.keys, ".")
+		if tag := f.sf.Tag.Get("validate"); tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				if err := checkRule(f.value, strings.TrimSpace(rule)); err != nil {
+					return fmt.Errorf("field %s: validate %q: %s", path, rule, err.Error())
+				}
+			}
+		}
+		if f.isLeaf() {
+			return nil
+		}
+		if v := indirect(f.value); v.IsValid() && v.CanAddr() {
+			if validator, ok := v.Addr().Interface().(Validator); ok {
+				if err := validator.Validate(); err != nil {
+					return fmt.Errorf("field %s: %s", path, err.Error())
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if validator, ok := structPtr.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+func checkRule(v reflect.Value, rule string) error {
+	name, param := rule, ""
+	if i := strings.Index(rule, "="); i >= 0 {
+		name, param = rule[:i], rule[i+1:]
+	}
+	if name == "required" {
+		if v.IsZero() {
+			return errors.New("the value is required")
+		}
+		return nil
+	}
+	if v = indirect(v); !v.IsValid() {
+		return nil
+	}
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return fmt.Errorf("invalid limit: %s", param)
+		}
+		n, what, err := measure(v)
+		if err != nil {
+			return err
+		}
+		if name == "min" && n < limit {
+			return fmt.Errorf("%s %v is less than %s", what, n, param)
+		}
+		if name == "max" && n > limit {
+			return fmt.Errorf("%s %v is greater than %s", what, n, param)
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, x := range strings.Fields(param) {
+			if x == s {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", s, param)
+	default:
+		return errors.New("unknown rule")
+	}
+	return nil
+}
+
+// measure returns the number, or the length of the value.
+func measure(v reflect.Value) (float64, string, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "value", nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), "value", nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "value", nil
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "length", nil
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), "length", nil
+	}
+	return 0, "", fmt.Errorf("unsupported type %s", v.Type().String())
+}
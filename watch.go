@@ -0,0 +1,178 @@
+package cfgo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// WatchInterval is the interval of polling the config file,
+	// used when inotify is unavailable.
+	WatchInterval = time.Second
+	// WatchDebounce is the quiet period waited for after the last change,
+	// so that a burst of writes causes only one reload.
+	WatchDebounce = 100 * time.Millisecond
+)
+
+// Watch starts watching the default config file.
+func Watch(errFunc func(error)) error {
+	return Default().Watch(errFunc)
+}
+
+// Unwatch stops watching the default config file.
+func Unwatch() {
+	Default().Unwatch()
+}
+
+// Watch starts watching the config file, and reloads it automatically
+// when it is changed by someone else.
+// It uses inotify on linux, and falls back to polling the modification time
+// and hash of the file. The rewrites done by cfgo itself are ignored.
+// The errors of automatic reloading are passed to errFunc, if it is not nil.
+func (c *Cfgo) Watch(errFunc func(error)) error {
+	c.lc.Lock()
+	defer c.lc.Unlock()
+	if c.watcher != nil {
+		return fmt.Errorf("[cfgo] already watching: %s", c.filename)
+	}
+	w := &watcher{
+		c:       c,
+		errFunc: errFunc,
+		notify:  make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+	}
+	stop, err := newNotifier(c.filename, w.signal)
+	if err != nil {
+		w.wg.Add(1)
+		go w.poll(WatchInterval)
+	} else {
+		w.stopNotifier = stop
+	}
+	w.wg.Add(1)
+	go w.run(WatchDebounce)
+	c.watcher = w
+	return nil
+}
+
+// Unwatch stops watching the config file.
+func (c *Cfgo) Unwatch() {
+	c.lc.Lock()
+	w := c.watcher
+	c.watcher = nil
+	c.lc.Unlock()
+	if w != nil {
+		w.close()
+	}
+}
+
+type watcher struct {
+	c            *Cfgo
+	errFunc      func(error)
+	notify       chan struct{}
+	closeCh      chan struct{}
+	stopNotifier func()
+	wg           sync.WaitGroup
+}
+
+// signal records a change without blocking.
+func (w *watcher) signal() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) close() {
+	if w.stopNotifier != nil {
+		w.stopNotifier()
+	}
+	close(w.closeCh)
+	w.wg.Wait()
+}
+
+// run debounces the changes and reloads the config.
+func (w *watcher) run(debounce time.Duration) {
+	defer w.wg.Done()
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-w.closeCh:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-w.notify:
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(debounce)
+			fire = timer.C
+		case <-fire:
+			fire = nil
+			w.reload()
+		}
+	}
+}
+
+func (w *watcher) reload() {
+	b, err := ioutil.ReadFile(w.c.filename)
+	if err == nil && bytes.Equal(b, w.c.Content()) {
+		// unchanged, or rewritten by cfgo itself
+		return
+	}
+	err = w.c.Reload()
+	if err != nil && w.errFunc != nil {
+		w.errFunc(err)
+	}
+}
+
+// poll checks the modification time and the hash of the file periodically.
+func (w *watcher) poll(interval time.Duration) {
+	defer w.wg.Done()
+	var (
+		modTime time.Time
+		size    int64
+		sum     [sha1.Size]byte
+	)
+	if fi, err := os.Stat(w.c.filename); err == nil {
+		modTime, size = fi.ModTime(), fi.Size()
+		if b, err := ioutil.ReadFile(w.c.filename); err == nil {
+			sum = sha1.Sum(b)
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.closeCh:
+			return
+		case <-ticker.C:
+			fi, err := os.Stat(w.c.filename)
+			if err != nil {
+				if !modTime.IsZero() {
+					modTime, size = time.Time{}, 0
+					w.signal()
+				}
+				continue
+			}
+			if fi.ModTime().Equal(modTime) && fi.Size() == size {
+				continue
+			}
+			modTime, size = fi.ModTime(), fi.Size()
+			b, err := ioutil.ReadFile(w.c.filename)
+			if err != nil {
+				continue
+			}
+			if s := sha1.Sum(b); s != sum {
+				sum = s
+				w.signal()
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+//go:build linux
+// +build linux
+
+package cfgo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// newNotifier watches the directory of the file with inotify,
+// so that the file can be replaced or recreated.
+func newNotifier(filename string, notify func()) (stop func(), err error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	dir, name := filepath.Split(filename)
+	_, err = syscall.InotifyAddWatch(fd, dir, syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE|
+		syscall.IN_CREATE|syscall.IN_DELETE|syscall.IN_MOVED_TO|syscall.IN_MOVED_FROM)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	// a non-blocking fd is managed by the runtime poller, so Close unblocks Read.
+	file := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				return
+			}
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				off += syscall.SizeofInotifyEvent
+				evName := strings.TrimRight(string(buf[off:off+int(ev.Len)]), "\x00")
+				off += int(ev.Len)
+				if evName == name || ev.Mask&syscall.IN_Q_OVERFLOW != 0 {
+					notify()
+				}
+			}
+		}
+	}()
+	return func() { file.Close() }, nil
+}
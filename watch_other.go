@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package cfgo
+
+import "errors"
+
+// newNotifier is not supported, the watcher falls back to polling.
+func newNotifier(filename string, notify func()) (stop func(), err error) {
+	return nil, errors.New("inotify is not supported")
+}